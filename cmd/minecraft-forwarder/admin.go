@@ -0,0 +1,189 @@
+package main
+
+import (
+    "crypto/subtle"
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "strings"
+
+    "github.com/hjjg200/minecraft-forwarder/pkg/manager"
+)
+
+// Admin
+// JSON API, every request needs the header Authorization: Bearer <token>
+//   GET  /servers
+//   POST /servers/<name>/start
+//   POST /servers/<name>/stop
+//   GET  /sessions
+//   POST /reload
+type AdminConfig struct {
+    Listen string `json:"listen"` // disabled if empty
+    Token string `json:"token"`
+}
+
+type ServerInfo struct {
+    Name string `json:"name"`
+    Aliases []string `json:"aliases"`
+    Port uint16 `json:"port"`
+    State string `json:"state"`
+    Addr string `json:"addr"`
+    Error string `json:"error,omitempty"`
+}
+
+// ListenAndServeAdmin serves the API, reloading the config with reload
+func ListenAndServeAdmin(cfg AdminConfig, reload func() error) error {
+
+    if cfg.Token == "" {
+        return fmt.Errorf("Admin token is required")
+    }
+
+    return http.ListenAndServe(cfg.Listen, adminHandler(cfg.Token, reload))
+
+}
+
+func adminHandler(token string, reload func() error) http.Handler {
+
+    mux := http.NewServeMux()
+    mux.HandleFunc("/servers", adminServers)
+    mux.HandleFunc("/servers/", adminServerAction)
+    mux.HandleFunc("/sessions", adminSessions)
+    mux.HandleFunc("/reload", func(w http.ResponseWriter, r *http.Request) {
+        adminReload(w, r, reload)
+    })
+
+    return adminAuth(token, mux)
+
+}
+
+func adminAuth(token string, next http.Handler) http.Handler {
+    expected := []byte("Bearer " + token)
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        given := []byte(r.Header.Get("Authorization"))
+        if subtle.ConstantTimeCompare(given, expected) != 1 {
+            writeError(w, http.StatusUnauthorized, fmt.Errorf("Unauthorized"))
+            return
+        }
+        next.ServeHTTP(w, r)
+    })
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(status)
+    json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+    writeJSON(w, status, map[string] string{"error": err.Error()})
+}
+
+func adminServers(w http.ResponseWriter, r *http.Request) {
+
+    if r.Method != http.MethodGet {
+        writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("Method not allowed"))
+        return
+    }
+
+    cfg, managers := snapshot()
+
+    infos := make([]ServerInfo, 0, len(cfg.Servers))
+    for _, server := range cfg.Servers {
+        info := ServerInfo{
+            Name: server.Name,
+            Aliases: server.Aliases,
+            Port: server.Port,
+            State: manager.StateName(manager.StateObscure),
+        }
+
+        m, ok := managers[server.uuid()]
+        if ok {
+            state, err := m.State()
+            info.State = manager.StateName(state)
+            info.Addr = m.Addr()
+            if err != nil {
+                info.Error = err.Error()
+            }
+        }
+
+        infos = append(infos, info)
+    }
+
+    writeJSON(w, http.StatusOK, infos)
+
+}
+
+func adminServerAction(w http.ResponseWriter, r *http.Request) {
+
+    if r.Method != http.MethodPost {
+        writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("Method not allowed"))
+        return
+    }
+
+    parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/servers/"), "/")
+    if len(parts) != 2 {
+        writeError(w, http.StatusNotFound, fmt.Errorf("Not found"))
+        return
+    }
+    name, action := parts[0], parts[1]
+
+    cfg, managers := snapshot()
+
+    var m manager.Manager
+    for _, server := range cfg.Servers {
+        if server.Name == name {
+            m = managers[server.uuid()]
+            break
+        }
+    }
+    if m == nil {
+        writeError(w, http.StatusNotFound, fmt.Errorf("No server named %s", name))
+        return
+    }
+
+    var err error
+    switch action {
+    case "start":
+        err = m.Start()
+    case "stop":
+        err = m.Stop()
+    default:
+        writeError(w, http.StatusNotFound, fmt.Errorf("Unknown action %s", action))
+        return
+    }
+    if err != nil {
+        writeError(w, http.StatusBadGateway, err)
+        return
+    }
+
+    writeJSON(w, http.StatusOK, map[string] bool{"ok": true})
+
+}
+
+func adminSessions(w http.ResponseWriter, r *http.Request) {
+
+    if r.Method != http.MethodGet {
+        writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("Method not allowed"))
+        return
+    }
+
+    writeJSON(w, http.StatusOK, sessionInfos())
+
+}
+
+func adminReload(w http.ResponseWriter, r *http.Request, reload func() error) {
+
+    if r.Method != http.MethodPost {
+        writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("Method not allowed"))
+        return
+    }
+
+    err := reload()
+    if err != nil {
+        writeError(w, http.StatusUnprocessableEntity, err)
+        return
+    }
+
+    writeJSON(w, http.StatusOK, map[string] bool{"ok": true})
+
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "net"
+    "net/http"
+    "net/http/httptest"
+    "testing"
+
+    "github.com/hjjg200/minecraft-forwarder/pkg/manager"
+)
+
+// adminManager counts the actions taken on it
+type adminManager struct {
+    state int
+    starts, stops int
+}
+
+func(am *adminManager) Start() error {
+    am.starts++
+    return nil
+}
+
+func(am *adminManager) Stop() error {
+    am.stops++
+    return nil
+}
+
+func(am *adminManager) State() (int, error) {
+    return am.state, nil
+}
+
+func(am *adminManager) Addr() string {
+    return "127.0.0.1:25566"
+}
+
+func(am *adminManager) Dial() (net.Conn, error) {
+    return nil, fmt.Errorf("Not dialable")
+}
+
+// adminServer serves the API over a config with the one server lobby
+func adminServer(t *testing.T, reload func() error) (*httptest.Server, *adminManager) {
+
+    server := ServerConfig{Name: "lobby", Port: 25565}
+    am := &adminManager{state: manager.StateStopped}
+
+    current.Lock()
+    old := current.config
+    oldManagers := current.managers
+    current.config = Config{Servers: []ServerConfig{server}}
+    current.managers = map[string] manager.Manager{server.uuid(): am}
+    current.Unlock()
+
+    t.Cleanup(func() {
+        current.Lock()
+        current.config = old
+        current.managers = oldManagers
+        current.Unlock()
+    })
+
+    ts := httptest.NewServer(adminHandler("secret", reload))
+    t.Cleanup(ts.Close)
+
+    return ts, am
+
+}
+
+func adminRequest(t *testing.T, ts *httptest.Server, method, path, token string, v interface{}) int {
+
+    req, err := http.NewRequest(method, ts.URL + path, nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if token != "" {
+        req.Header.Set("Authorization", "Bearer " + token)
+    }
+
+    rsp, err := http.DefaultClient.Do(req)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer rsp.Body.Close()
+
+    if v != nil {
+        err = json.NewDecoder(rsp.Body).Decode(v)
+        if err != nil {
+            t.Fatal(err)
+        }
+    }
+
+    return rsp.StatusCode
+
+}
+
+func TestAdminAuth(t *testing.T) {
+
+    ts, _ := adminServer(t, nil)
+
+    for _, token := range []string{"", "wrong", "secre", "secrets", "SECRET"} {
+        if status := adminRequest(t, ts, "GET", "/servers", token, nil); status != http.StatusUnauthorized {
+            t.Errorf("Token %q got %d", token, status)
+        }
+    }
+
+    var infos []ServerInfo
+    if status := adminRequest(t, ts, "GET", "/servers", "secret", &infos); status != http.StatusOK {
+        t.Fatal(status)
+    }
+    if len(infos) != 1 || infos[0].Name != "lobby" || infos[0].State != "stopped" ||
+        infos[0].Addr != "127.0.0.1:25566" {
+        t.Errorf("%+v", infos)
+    }
+
+}
+
+func TestAdminActions(t *testing.T) {
+
+    reloads := 0
+    var reloadErr error
+    ts, am := adminServer(t, func() error {
+        reloads++
+        return reloadErr
+    })
+
+    samples := []struct {
+        method, path string
+        status int
+    }{
+        {"POST", "/servers/lobby/start", http.StatusOK},
+        {"POST", "/servers/lobby/stop", http.StatusOK},
+        {"GET", "/servers/lobby/start", http.StatusMethodNotAllowed},
+        {"POST", "/servers/lobby/restart", http.StatusNotFound},
+        {"POST", "/servers/hub/start", http.StatusNotFound},
+        {"POST", "/reload", http.StatusOK},
+        {"GET", "/reload", http.StatusMethodNotAllowed},
+    }
+    for _, sample := range samples {
+        status := adminRequest(t, ts, sample.method, sample.path, "secret", nil)
+        if status != sample.status {
+            t.Errorf("%s %s got %d, expected %d", sample.method, sample.path, status, sample.status)
+        }
+    }
+    if am.starts != 1 || am.stops != 1 || reloads != 1 {
+        t.Errorf("%d starts, %d stops and %d reloads", am.starts, am.stops, reloads)
+    }
+
+    reloadErr = fmt.Errorf("Invalid config")
+    var rsp map[string] string
+    status := adminRequest(t, ts, "POST", "/reload", "secret", &rsp)
+    if status != http.StatusUnprocessableEntity || rsp["error"] != "Invalid config" {
+        t.Errorf("Failed reload got %d %v", status, rsp)
+    }
+
+}
+
+func TestAdminSessions(t *testing.T) {
+
+    ts, _ := adminServer(t, nil)
+
+    client, src := net.Pipe()
+    defer client.Close()
+    sess := openSession("lobby", "Steve", src)
+    defer sess.Close()
+
+    // The replayed handshake and login start, then forwarded bytes
+    sess.countUp(30)
+    go client.Write([]byte{0x01, 0x02})
+    sess.Read(make([]byte, 2))
+    go client.Read(make([]byte, 1))
+    sess.Write([]byte{0x03})
+
+    var infos []SessionInfo
+    if status := adminRequest(t, ts, "GET", "/sessions", "secret", &infos); status != http.StatusOK {
+        t.Fatal(status)
+    }
+    if len(infos) != 1 || infos[0].Player != "Steve" || infos[0].Up != 32 || infos[0].Down != 1 {
+        t.Errorf("%+v", infos)
+    }
+
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+    "sync"
+    "time"
+
+    "github.com/hjjg200/minecraft-forwarder/pkg/manager"
+)
+
+// Boots
+// When each server was started and how long its starts usually take, for
+// the estimates in messages, and since when it has been seen running
+var boots = struct {
+    sync.Mutex
+    started map[string] time.Time // by server name
+    took map[string] time.Duration
+    up map[string] time.Time
+}{
+    started: make(map[string] time.Time),
+    took: make(map[string] time.Duration),
+    up: make(map[string] time.Time),
+}
+
+func bootStarted(server string) {
+    boots.Lock()
+    defer boots.Unlock()
+    boots.started[server] = time.Now()
+}
+
+// bootObserved learns the boot time once a started server is seen running,
+// and notes when the server came up for its uptime
+func bootObserved(server string, state int) {
+
+    boots.Lock()
+    defer boots.Unlock()
+
+    if state != manager.StateRunning {
+        delete(boots.up, server)
+    } else if _, ok := boots.up[server]; !ok {
+        boots.up[server] = time.Now()
+    }
+
+    started, ok := boots.started[server]
+    switch {
+    case !ok:
+        return
+    case state == manager.StateStopping:
+        // Stopped before it came up
+        delete(boots.started, server)
+        return
+    case state != manager.StateRunning:
+        return
+    }
+    delete(boots.started, server)
+
+    took := time.Since(started)
+    if last, ok := boots.took[server]; ok {
+        took = (last * 3 + took) / 4
+    }
+    boots.took[server] = took
+
+}
+
+// bootProgress returns the time since the start and the estimated time left
+func bootProgress(server string) (time.Duration, time.Duration) {
+
+    boots.Lock()
+    defer boots.Unlock()
+
+    started, ok := boots.started[server]
+    if !ok {
+        return 0, 0
+    }
+    elapsed := time.Since(started)
+
+    took, ok := boots.took[server]
+    if !ok || took < elapsed {
+        return elapsed, 0
+    }
+
+    return elapsed, took - elapsed
+
+}
+
+// uptime returns how long the server has been seen running, 0 if it is not
+func uptime(server string) time.Duration {
+
+    boots.Lock()
+    defer boots.Unlock()
+
+    up, ok := boots.up[server]
+    if !ok {
+        return 0
+    }
+    return time.Since(up)
+
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+    "testing"
+    "time"
+
+    "github.com/hjjg200/minecraft-forwarder/pkg/manager"
+)
+
+// bootHistory sets the start and the learned boot time of a server
+func bootHistory(server string, ago, took time.Duration) {
+    boots.Lock()
+    defer boots.Unlock()
+    boots.started[server] = time.Now().Add(-ago)
+    if took != 0 {
+        boots.took[server] = took
+    }
+}
+
+func near(d, expected time.Duration) bool {
+    return d > expected - time.Second && d < expected + time.Second
+}
+
+func TestBootProgress(t *testing.T) {
+
+    // Not started
+    if elapsed, eta := bootProgress("boot-none"); elapsed != 0 || eta != 0 {
+        t.Error("Progress of a server that was not started", elapsed, eta)
+    }
+
+    // No history to estimate by
+    bootHistory("boot-new", 20 * time.Second, 0)
+    if elapsed, eta := bootProgress("boot-new"); !near(elapsed, 20 * time.Second) || eta != 0 {
+        t.Error("Progress without history", elapsed, eta)
+    }
+
+    bootHistory("boot-known", 20 * time.Second, time.Minute)
+    if elapsed, eta := bootProgress("boot-known"); !near(elapsed, 20 * time.Second) || !near(eta, 40 * time.Second) {
+        t.Error("Progress with history", elapsed, eta)
+    }
+
+    // Taking longer than usual
+    bootHistory("boot-slow", 2 * time.Minute, time.Minute)
+    if elapsed, eta := bootProgress("boot-slow"); !near(elapsed, 2 * time.Minute) || eta != 0 {
+        t.Error("Progress of a slow boot", elapsed, eta)
+    }
+
+}
+
+func TestBootObserved(t *testing.T) {
+
+    boots.Lock()
+    delete(boots.took, "boot-avg")
+    boots.Unlock()
+
+    // The first boot is learned as is
+    bootHistory("boot-avg", 80 * time.Second, 0)
+    bootObserved("boot-avg", manager.StatePending)
+    bootObserved("boot-avg", manager.StateRunning)
+    boots.Lock()
+    took := boots.took["boot-avg"]
+    boots.Unlock()
+    if !near(took, 80 * time.Second) {
+        t.Error("First boot took", took)
+    }
+    if elapsed, eta := bootProgress("boot-avg"); elapsed != 0 || eta != 0 {
+        t.Error("Progress after the boot", elapsed, eta)
+    }
+
+    // Later ones weigh a quarter
+    bootHistory("boot-avg", 40 * time.Second, 0)
+    bootObserved("boot-avg", manager.StateRunning)
+    boots.Lock()
+    took = boots.took["boot-avg"]
+    boots.Unlock()
+    if !near(took, 70 * time.Second) {
+        t.Error("Average boot took", took)
+    }
+
+    // Stopped before it came up, nothing is learned
+    bootHistory("boot-avg", 10 * time.Second, 0)
+    bootObserved("boot-avg", manager.StateStopping)
+    boots.Lock()
+    took = boots.took["boot-avg"]
+    _, started := boots.started["boot-avg"]
+    boots.Unlock()
+    if started || !near(took, 70 * time.Second) {
+        t.Error("Aborted boot was learned", took, started)
+    }
+
+}
+
+func TestUptime(t *testing.T) {
+
+    if uptime("boot-up") != 0 {
+        t.Error("Uptime before running")
+    }
+
+    bootObserved("boot-up", manager.StateRunning)
+    time.Sleep(10 * time.Millisecond)
+    if uptime("boot-up") < 10 * time.Millisecond {
+        t.Error("No uptime while running")
+    }
+    bootObserved("boot-up", manager.StateRunning)
+    if uptime("boot-up") < 10 * time.Millisecond {
+        t.Error("Uptime was reset while running")
+    }
+
+    bootObserved("boot-up", manager.StateStopping)
+    if uptime("boot-up") != 0 {
+        t.Error("Uptime after running")
+    }
+
+}
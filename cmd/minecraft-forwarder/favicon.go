@@ -0,0 +1,50 @@
+package main
+
+import (
+    "fmt"
+
+    "github.com/hjjg200/minecraft-forwarder/pkg/manager"
+    "github.com/hjjg200/minecraft-forwarder/pkg/packet"
+)
+
+// Favicons
+// Read and encoded once per config load, so broken files reject the config
+func loadFavicons(cfg *Config) error {
+
+    for i := range cfg.Servers {
+        server := &cfg.Servers[i]
+        server.favicons = make(map[string] string)
+
+        paths := map[string] string{"": server.Favicon}
+        for state, path := range server.StateFavicons {
+            switch state {
+            case "stopped", "pending", "stopping", "obscure":
+            default:
+                return fmt.Errorf("Server %s has a favicon for unknown state %s", server.Name, state)
+            }
+            paths[state] = path
+        }
+
+        for state, path := range paths {
+            if path == "" {
+                continue
+            }
+            favicon, err := packet.LoadFavicon(path)
+            if err != nil {
+                return fmt.Errorf("Server %s: %v", server.Name, err)
+            }
+            server.favicons[state] = favicon
+        }
+    }
+
+    return nil
+
+}
+
+// favicon returns the icon for the state, falling back to the default one
+func(scfg ServerConfig) favicon(state int) string {
+    if favicon, ok := scfg.favicons[manager.StateName(state)]; ok {
+        return favicon
+    }
+    return scfg.favicons[""]
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+    "time"
+
+    "github.com/hjjg200/minecraft-forwarder/pkg/manager"
+    "github.com/hjjg200/minecraft-forwarder/pkg/metrics"
+)
+
+type MetricsConfig struct {
+    Listen string `json:"listen"` // serves /metrics, disabled if empty
+}
+
+// instrumentedManager
+// Records metrics for the starts and state queries of a manager, and
+// seeds the status cache once its server is running. It is kept across
+// reloads, so the config of its server is looked up by uuid.
+type instrumentedManager struct {
+    manager.Manager
+    name string
+    uuid string
+}
+
+func newInstrumentedManager(m manager.Manager, server ServerConfig) *instrumentedManager {
+    return &instrumentedManager{m, server.Name, server.uuid()}
+}
+
+// server returns the current config of the server, false if it is not
+// applied yet or was removed
+func(im *instrumentedManager) server() (ServerConfig, bool) {
+    current.RLock()
+    defer current.RUnlock()
+    server, ok := current.servers[im.uuid]
+    return server, ok
+}
+
+func(im *instrumentedManager) Start() error {
+    metrics.StartAttempts.Inc(im.name)
+    err := im.Manager.Start()
+    if err != nil {
+        metrics.StartFailures.Inc(im.name)
+    } else {
+        bootStarted(im.name)
+    }
+    return err
+}
+
+func(im *instrumentedManager) State() (int, error) {
+
+    start := time.Now()
+    state, err := im.Manager.State()
+    metrics.StateQueries.Observe(time.Since(start).Seconds(), im.name)
+
+    if err != nil {
+        metrics.StateErrors.Inc(im.name)
+    }
+    metrics.States.Set(float64(state), im.name)
+    if err == nil {
+        bootObserved(im.name, state)
+        if server, ok := im.server(); ok {
+            seedStatus(server, im.Manager, state)
+        }
+    }
+
+    return state, err
+
+}
+
+func(im *instrumentedManager) Closable() error {
+    return manager.Closable(im.Manager)
+}
+
+func(im *instrumentedManager) Close() error {
+    return manager.Close(im.Manager)
+}
+
+func(im *instrumentedManager) CanStart() bool {
+    return manager.CanStart(im.Manager)
+}
+
+func(im *instrumentedManager) CanStop() bool {
+    return manager.CanStop(im.Manager)
+}
+
+func(im *instrumentedManager) Addrs() []string {
+    return manager.Addrs(im.Manager)
+}
+
+func(im *instrumentedManager) Describe() string {
+    return manager.Describe(im.Manager)
+}
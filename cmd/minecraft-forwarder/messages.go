@@ -0,0 +1,86 @@
+package main
+
+import (
+    "fmt"
+    "reflect"
+    "strings"
+    "text/template"
+
+    "github.com/hjjg200/minecraft-forwarder/pkg/packet"
+)
+
+// Messages
+// Messages and banners are templates of formatted text, rendered for each
+// client with MessageData, e.g.
+//   Starting {{.Server}} for {{.Player}}{{if .ETA}}, {{.ETA}}s left{{end}}
+type MessageData struct {
+    Server string
+    Hostname string // as sent in the handshake, without mod loader markers
+    Protocol int32
+    Player string // empty in status requests
+    State string // of the manager, e.g. "pending"
+    Machine string // own state of the machine, e.g. the EC2 "stopping", empty if unknown
+    Elapsed int // seconds since the server was started, 0 if not starting
+    Uptime int // seconds since the server was first seen running, 0 if it is not
+    ETA int // estimated seconds until it is up, 0 if unknown
+    Online int // last known player count
+    Versions string // accepted by the server, e.g. 1.20.5-1.21.1, empty if any
+}
+
+// compileMessages parses the templates of the messages and banners,
+// checking that they render to valid formatted text
+func compileMessages(cfg *Config) error {
+
+    cfg.templates = make(map[string] *template.Template)
+
+    for _, v := range []interface{}{cfg.Messages, cfg.Banners} {
+        rv := reflect.ValueOf(v)
+        for i := 0; i < rv.NumField(); i++ {
+            field := rv.Type().Field(i)
+            if field.Type.Kind() != reflect.String {
+                continue
+            }
+            name := field.Tag.Get("json")
+            text := rv.Field(i).String()
+
+            tmpl, err := template.New(name).Parse(text)
+            if err != nil {
+                return fmt.Errorf("Message %s: %v", name, err)
+            }
+
+            var b strings.Builder
+            err = tmpl.Execute(&b, MessageData{})
+            if err == nil {
+                _, err = packet.ParseFormat(b.String())
+            }
+            if err != nil {
+                return fmt.Errorf("Message %s: %v", name, err)
+            }
+
+            cfg.templates[text] = tmpl
+        }
+    }
+
+    return nil
+
+}
+
+// render executes the template of the message, which is used as is when
+// it is not one of the configured ones
+func(cfg Config) render(msg string, data MessageData) string {
+
+    tmpl, ok := cfg.templates[msg]
+    if !ok {
+        return msg
+    }
+
+    var b strings.Builder
+    err := tmpl.Execute(&b, data)
+    if err != nil {
+        fmt.Println("Message template failed:", err)
+        return msg
+    }
+
+    return b.String()
+
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+    "strings"
+    "testing"
+)
+
+func TestCompileMessages(t *testing.T) {
+
+    cfg := Config{Messages: DefaultConfig.Messages, Banners: DefaultConfig.Banners}
+    if err := compileMessages(&cfg); err != nil {
+        t.Fatal(err)
+    }
+
+    // Templates that do not parse or name no field are refused on load
+    for _, text := range []string{"{{.ETA", "{{.Cost}}s", "{{if .ETA}}left"} {
+        cfg.Messages.Pending = text
+        err := compileMessages(&cfg)
+        if err == nil || !strings.Contains(err.Error(), "pending") {
+            t.Errorf("%q got %v", text, err)
+        }
+    }
+
+}
+
+func TestRender(t *testing.T) {
+
+    cfg := Config{Messages: DefaultConfig.Messages, Banners: DefaultConfig.Banners}
+    cfg.Messages.Stopping = "{{.Server}} is {{.State}}{{if .Machine}} ({{.Machine}}){{end}}"
+    cfg.Messages.Ready = "Up for {{.Uptime}}s, {{.Online}} online"
+    if err := compileMessages(&cfg); err != nil {
+        t.Fatal(err)
+    }
+
+    data := MessageData{Server: "lobby", State: "stopping", Uptime: 42, Online: 3}
+    samples := []struct {
+        msg, expected string
+    }{
+        {cfg.Messages.Stopping, "lobby is stopping"},
+        {cfg.Messages.Ready, "Up for 42s, 3 online"},
+        {cfg.Messages.Pending, "PENDING..."},
+        // Not a configured message
+        {"{{.Server}}", "{{.Server}}"},
+    }
+    for _, sample := range samples {
+        if out := cfg.render(sample.msg, data); out != sample.expected {
+            t.Errorf("%q != %q", out, sample.expected)
+        }
+    }
+
+    data.Machine = "shutting-down"
+    data.ETA = 30
+    if out := cfg.render(cfg.Messages.Stopping, data); out != "lobby is stopping (shutting-down)" {
+        t.Error(out)
+    }
+    if out := cfg.render(cfg.Messages.Pending, data); out != "PENDING... about 30s left" {
+        t.Error(out)
+    }
+
+}
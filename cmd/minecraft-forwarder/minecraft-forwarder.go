@@ -2,15 +2,20 @@ package main
 
 import (
     "encoding/json"
+    "errors"
     "fmt"
     "io"
-    "io/ioutil"
     "net"
     "os"
-    "sync"
+    "os/signal"
+    "syscall"
+    "text/template"
+    "time"
 
     "github.com/hjjg200/minecraft-forwarder/pkg/packet"
     "github.com/hjjg200/minecraft-forwarder/pkg/manager"
+    "github.com/hjjg200/minecraft-forwarder/pkg/metrics"
+    "github.com/hjjg200/minecraft-forwarder/pkg/route"
 
     "github.com/hjjg200/act"
     "github.com/hjjg200/go-jsoncfg"
@@ -20,9 +25,23 @@ type (
 
     ServerConfig struct {
         Name string `json:"name"`
-        Aliases []string `json:"aliases"`
+        Aliases []string `json:"aliases"` // may be wildcards, e.g. *.example.com, or regexes, e.g. ~(\w+)\.example\.com
+        Backend string `json:"backend"` // dialed instead of the manager's address, e.g. $1.internal:25565
+        BackendHosts []string `json:"backendHosts"` // hosts a backend with captures may name, e.g. *.internal
         Port uint16 `json:"port"`
-        Forward interface{} `json:"forward"`
+        Forward interface{} `json:"forward"` // a forward block, or a list of them for several backends
+        Balance string `json:"balance"` // among several backends: round-robin, least-connections, lowest-ping or fill-first
+        IdleMinutes int `json:"idleMinutes"` // 0 disables idle shutdown
+        ProxyProtocol int `json:"proxyProtocol"` // PROXY header version sent to the backend, 0 disables
+        Forwarding string `json:"forwarding"` // player info forwarding: "", "legacy" or "modern"
+        ForwardingSecret string `json:"forwardingSecret"` // for modern forwarding
+        RCON manager.RCONConfig `json:"rcon"` // used for stopping when a password is set
+        Favicon string `json:"favicon"` // 64x64 PNG shown in the server list
+        StateFavicons map[string] string `json:"stateFavicons"` // by state name, e.g. "stopped"
+        MinProtocol int32 `json:"minProtocol"` // accepted client protocols, 0 leaving the end open
+        MaxProtocol int32 `json:"maxProtocol"`
+        LearnProtocol bool `json:"learnProtocol"` // without a range, accept only the protocol of the cached status
+        favicons map[string] string // encoded, by state name, "" for the default
     }
 
     MessageConfig struct {
@@ -32,12 +51,25 @@ type (
         Obscure string `json:"obscure"`
         Started string `json:"started"`
         StartFailed string `json:"startFailed"`
+        Ready string `json:"ready"`
+        Incompatible string `json:"incompatible"`
+        Offline string `json:"offline"` // for servers that cannot be started from here
     }
 
     Config struct {
         Listen []string `json:"listen"`
+        AcceptProxy map[string] []string `json:"acceptProxy"` // listen address to trusted CIDRs
         Servers []ServerConfig `json:"servers"`
+        Default string `json:"default"` // name of the server of unknown hostnames and IP addresses
         Messages MessageConfig `json:"messages"`
+        Banners BannerConfig `json:"banners"` // replace the messages when a cached status exists
+        StatusCache string `json:"statusCache"` // file, not persisted if empty
+        Admin AdminConfig `json:"admin"`
+        Metrics MetricsConfig `json:"metrics"`
+        WatchConfig bool `json:"watchConfig"` // reload when the file changes, besides SIGHUP
+        templates map[string] *template.Template // of the messages and banners, by their text
+        routes *route.Table
+        servers map[string] *ServerConfig // by uuid
     }
 
 )
@@ -56,15 +88,30 @@ var DefaultConfig = Config{
         ":25565",
     },
 
+    AcceptProxy: map[string] []string{},
+
     Messages: MessageConfig{
         Stopped: "STOPPED\nAttempt login to start it up",
-        Pending: "PENDING...",
+        Pending: "PENDING...{{if .ETA}} about {{.ETA}}s left{{end}}",
         Stopping: "STOPPING...",
         Obscure: "STATE OBSCURE",
         Started: "Successfully started the server!",
         StartFailed: "Failed to start the server!",
+        Ready: "The server is ready!\nRejoin now",
+        Incompatible: "Unsupported version, use {{.Versions}}",
+        Offline: "The server is offline and cannot be started from here",
+    },
+
+    Banners: BannerConfig{
+        Stopped: "Sleeping, join to wake it up",
+        Pending: "Starting up...",
+        Stopping: "Shutting down...",
+        Obscure: "State unknown",
+        Offline: "Offline",
     },
 
+    StatusCache: "./status-cache.json",
+
     Servers: []ServerConfig{
         {
             Name: "example.com",
@@ -78,8 +125,11 @@ var DefaultConfig = Config{
 
 }
 
-var appConfig Config
-var managers = make(map[string] manager.Manager)
+const (
+    limboTimeout = 10 * time.Minute
+    configPath = "./config.json"
+    configPoll = 2 * time.Second
+)
 
 func main() {
 
@@ -88,146 +138,321 @@ func main() {
     act.Try(err)
     act.Try(cfgparser.SetSubDefault(&DefaultServerConfig))
 
-    cfgpath := "./config.json"
-    cfgfile, err := os.OpenFile(cfgpath, os.O_RDONLY, 0600)
+    _, err = os.Stat(configPath)
     if os.IsNotExist(err) {
-        cfgfile, err = os.OpenFile(cfgpath, os.O_WRONLY | os.O_CREATE, 0600)
+        cfgfile, err := os.OpenFile(configPath, os.O_WRONLY | os.O_CREATE, 0600)
         act.Try(err)
 
         enc := json.NewEncoder(cfgfile)
         enc.SetIndent("", "  ")
         act.Try(enc.Encode(DefaultConfig))
-
-        appConfig = DefaultConfig
+        cfgfile.Close()
     } else if err != nil {
         panic(err)
-    } else {
-        data, err := ioutil.ReadAll(cfgfile)
-        act.Try(err)
-        act.Try(cfgparser.Parse(data, &appConfig))
     }
 
-    // Create managers
-    for _, server := range appConfig.Servers {
-        data, err := json.Marshal(server.Forward)
-        act.Try(err)
+    appConfig, err := readConfig(cfgparser, configPath)
+    act.Try(err)
+    act.Try(apply(appConfig))
+    act.Try(loadStatuses(appConfig.StatusCache))
+
+    // Admin
+    if appConfig.Admin.Listen != "" {
+        go func() {
+            reload := func() error {
+                return reloadConfig(cfgparser)
+            }
+            fmt.Println("Admin exception:", ListenAndServeAdmin(appConfig.Admin, reload))
+        }()
+    }
 
-        var m manager.Manager
-        switch server.Forward.(map[string] interface{})["type"].(string) {
-        case "nop":
-            m = manager.NewNopManager()
-        case "ec2":
-            ec2, err := manager.NewEC2ManagerJson(data)
-            act.Try(err)
-            m = ec2
-        default:
-            panic("Unknown server forward type")
+    // Metrics
+    if appConfig.Metrics.Listen != "" {
+        go func() {
+            fmt.Println("Metrics exception:", metrics.ListenAndServe(appConfig.Metrics.Listen))
+        }()
+    }
+
+    // Reload on SIGHUP or when the file changes
+    hup := make(chan os.Signal, 1)
+    signal.Notify(hup, syscall.SIGHUP)
+
+    var poll <-chan time.Time
+    if appConfig.WatchConfig {
+        poll = time.NewTicker(configPoll).C
+    }
+    modTime := configModTime()
+
+    for {
+        select {
+        case <-hup:
+        case <-poll:
+            if t := configModTime(); t.Equal(modTime) {
+                continue
+            }
         }
+        modTime = configModTime()
 
-        managers[server.uuid()] = m
+        reloadConfig(cfgparser)
     }
 
-    // Loop
-    var wg sync.WaitGroup
-    wg.Add(len(appConfig.Servers))
+}
 
-    for _, each := range appConfig.Listen {
-        go func(addr string) {
+// reloadConfig reads the config file again and applies it
+func reloadConfig(parser *jsoncfg.Parser) error {
 
-            handler := packet.HandlerFunc(func(src net.Conn, hs packet.Handshake) {
+    cfg, err := readConfig(parser, configPath)
+    if err == nil {
+        err = apply(cfg)
+    }
+    if err != nil {
+        fmt.Println("Config reload rejected, keeping the old one:", err)
+        return err
+    }
+    fmt.Println("Config reloaded")
 
-                // Catch panic
-                defer act.Catch(func(err error) {
-                    if err == io.EOF {
-                        return
-                    }
-                    fmt.Println(err, act.Stack())
-                })
-
-                // Find matching server config
-                var server *ServerConfig
-Loop:
-                for _, s := range appConfig.Servers {
-                    if hs.Address == s.Name {
-                        server = &s
-                        break Loop
-                    }
-                    for _, alias := range s.Aliases {
-                        if hs.Address == alias {
-                            server = &s
-                            break Loop
-                        }
-                    }
-                }
+    return nil
 
-                if server == nil {
-                    src.Close()
-                    fmt.Println("No server was found for", hs.Address)
-                    return
-                }
+}
 
-                // Check state
-                m, ok := managers[server.uuid()]
-                act.Assert(ok, fmt.Errorf("Manager for server is not found"))
-
-                state, err := m.State()
-                act.Try(err)
-
-                // Handle each state
-                respond := func(msg, color string) {
-                    packet.ServeResponse(src, hs, packet.Response{
-                        Version: packet.VersionStruct{
-                            Name: "",
-                            Protocol: -1,
-                        },
-                        Description: packet.Chat{
-                            ChatElem: packet.ChatElem{
-                                Text: msg,
-                                Color: color,
-                            },
-                        },
-                    })
-                }
-                switch state {
-                case manager.StateStopped:
-                    if hs.NextState == packet.StateLogin {
-                        var c packet.Chat
-                        if m.Start() != nil {
-                            c.Text = appConfig.Messages.StartFailed
-                            c.Color = "red"
-                        } else {
-                            c.Text = appConfig.Messages.Started
-                            c.Color = "green"
-                        }
-                        packet.ServeDisconnect(src, hs, c)
-                        return
-                    }
-                    respond(appConfig.Messages.Stopped, "red")
-                    return
-                case manager.StatePending:
-                    respond(appConfig.Messages.Pending, "gold")
-                    return
-                case manager.StateRunning:
-                    dst, err := m.Dial()
-                    act.Try(err)
-                    packet.Forward(src, hs, dst)
+func configModTime() time.Time {
+    info, err := os.Stat(configPath)
+    if err != nil {
+        return time.Time{}
+    }
+    return info.ModTime()
+}
+
+func handle(src net.Conn, hs packet.Handshake) {
+
+    // Catch panic
+    defer act.Catch(func(err error) {
+        if err == io.EOF {
+            return
+        }
+        fmt.Println(err, act.Stack())
+    })
+
+    cfg, managers := snapshot()
+
+    // Find matching server config, by the hostname without the markers of
+    // modded clients
+    hostname := hs.Hostname()
+    server, match, _ := cfg.route(hostname)
+
+    // Legacy pings before 1.6 have no address
+    if server == nil && hs.Legacy && hostname == "" &&
+        len(cfg.Servers) > 0 {
+        server = &cfg.Servers[0]
+    }
+
+    serverName := "unknown"
+    if server != nil {
+        serverName = server.Name
+    }
+    intent := metrics.NextStateName(hs.NextState)
+    if hs.Legacy {
+        intent = "legacy"
+    }
+    metrics.Handshakes.Inc(intent, serverName)
+
+    if server == nil {
+        metrics.UnknownHosts.Inc()
+        src.Close()
+        fmt.Println("No server was found for", hostname)
+        return
+    }
+
+    // Check state
+    m, ok := managers[server.uuid()]
+    act.Assert(ok, fmt.Errorf("Manager for server is not found"))
+
+    state, err := m.State()
+    act.Try(err)
+
+    // Backends named by the client have statuses of their own
+    addr, err := server.backendAddr(match, m.Addr())
+    if err != nil {
+        src.Close()
+        fmt.Println("Server", server.Name, err)
+        return
+    }
+    statusKey := server.statusKey(addr)
+
+    // Transferred clients log in as usual
+    if hs.NextState == packet.StateTransfer {
+        hs.NextState = packet.StateLogin
+    }
+
+    // Handle each state
+    data := MessageData{
+        Server: server.Name,
+        Hostname: hostname,
+        Protocol: hs.Protocol,
+        State: manager.StateName(state),
+        Machine: manager.Describe(m),
+    }
+    if cached, ok := cachedResponse(statusKey); ok {
+        data.Online = cached.Players.Online
+    }
+    // Legacy pings have protocols of their own
+    protocols, limited := server.protocols(statusKey)
+    accepted := !limited || hs.Legacy || protocols.accepts(hs.Protocol)
+    if limited {
+        data.Versions = protocols.String()
+    }
+    chat := func(msg, color string) packet.Chat {
+        elapsed, eta := bootProgress(server.Name)
+        data.Elapsed = int(elapsed.Seconds())
+        data.ETA = int(eta.Seconds())
+        data.Uptime = int(uptime(server.Name).Seconds())
+
+        c, err := packet.ParseFormat(cfg.render(msg, data))
+        if err != nil {
+            // Messages are checked when the config is read
+            c = packet.Chat{Text: msg}
+        }
+        c.Color = color
+        if hs.Protocol < packet.ProtocolHexColor {
+            c = packet.Chat{Text: c.Legacy()}
+        }
+        return c
+    }
+    respond := func(msg, banner, color string) {
+        rsp := packet.Response{
+            Version: packet.EchoVersion(hs.Protocol),
+            Description: chat(msg, color),
+        }
+        if cached, ok := cachedResponse(statusKey); ok {
+            rsp = cached.Overlay(chat(banner, color))
+        }
+        if !accepted {
+            rsp.Version = protocols.incompatible(hs.Protocol)
+        }
+        if favicon := server.favicon(state); favicon != "" {
+            rsp.Favicon = favicon
+        }
+        packet.ServeResponse(src, hs, rsp)
+    }
+    disconnect := func(msg, color string) {
+        src.Write(packet.Disconnect{Reason: chat(msg, color)}.Bytes())
+    }
+
+    // Clients the server cannot take are not let in, nor is it started
+    if !accepted && hs.NextState == packet.StateLogin {
+        defer src.Close()
+
+        start, err := packet.ReadLoginStart(src)
+        act.Try(err)
+        data.Player = start.Name
+        fmt.Println("Incompatible protocol", hs.Protocol, "of", start.Name)
+
+        disconnect(cfg.Messages.Incompatible, "red")
+        return
+    }
+
+    switch state {
+    case manager.StateStopped, manager.StatePending:
+        if hs.NextState == packet.StateLogin {
+            defer src.Close()
+
+            start, err := packet.ReadLoginStart(src)
+            act.Try(err)
+            data.Player = start.Name
+
+            if state == manager.StateStopped {
+                err := m.Start()
+                if errors.Is(err, manager.ErrNotSupported) {
+                    disconnect(cfg.Messages.Offline, "red")
                     return
-                case manager.StateStopping:
-                    respond(appConfig.Messages.Stopping, "red")
+                } else if err != nil {
+                    disconnect(cfg.Messages.StartFailed, "red")
                     return
                 }
+                data.State = manager.StateName(manager.StatePending)
+            }
+            if !packet.CanLimbo(hs.Protocol) {
+                if state == manager.StateStopped {
+                    disconnect(cfg.Messages.Started, "green")
+                } else {
+                    disconnect(cfg.Messages.Pending, "gold")
+                }
+                return
+            }
+
+            // Hold the player until the backend is up
+            limbo := packet.Limbo{
+                Ready: func() (bool, error) {
+                    state, err := m.State()
+                    if err != nil {
+                        fmt.Println("Limbo state check failed:", err)
+                    }
+                    return state == manager.StateRunning, nil
+                },
+                Timeout: limboTimeout,
+                Rejoin: chat(cfg.Messages.Ready, "green"),
+                Failed: chat(cfg.Messages.StartFailed, "red"),
+            }
+            act.Try(limbo.Serve(src, hs, start))
+            return
+        }
+        if state == manager.StateStopped && !manager.CanStart(m) {
+            respond(cfg.Messages.Offline, cfg.Banners.Offline, "red")
+        } else if state == manager.StateStopped {
+            respond(cfg.Messages.Stopped, cfg.Banners.Stopped, "red")
+        } else {
+            respond(cfg.Messages.Pending, cfg.Banners.Pending, "gold")
+        }
+        return
+    case manager.StateRunning:
+        refreshStatus(statusKey, addr, server.ProxyProtocol)
+
+        var dst net.Conn
+        if server.Backend != "" {
+            dst, err = dialBackend(addr)
+        } else {
+            dst, err = m.Dial()
+        }
+        act.Try(err)
+        if server.ProxyProtocol > 0 {
+            err = packet.WriteProxyHeader(dst, server.ProxyProtocol, src.RemoteAddr(), src.LocalAddr())
+            act.Try(err)
+        }
 
-                respond(appConfig.Messages.Obscure, "gray")
-
-            })
+        var start packet.LoginStart
+        if hs.NextState == packet.StateLogin {
+            start, err = packet.ReadLoginStart(src)
+            act.Try(err)
+        }
 
-            packet.ListenAndServe(addr, handler)
-            wg.Done()
+        sess := openSession(server.Name, start.Name, src)
+        defer sess.Close()
+        if !hs.Legacy {
+            sess.countUp(len(hs.Bytes()))
+        }
+        if hs.NextState == packet.StateLogin {
+            sess.countUp(len(start.Bytes()))
+        }
 
-        }(each)
+        switch {
+        case hs.NextState != packet.StateLogin:
+            packet.Forward(sess, hs, dst)
+        // Player info forwarding
+        case server.Forwarding == "legacy":
+            packet.ForwardLegacy(sess, hs, start, dst)
+        case server.Forwarding == "modern":
+            secret := []byte(server.ForwardingSecret)
+            act.Try(packet.ForwardModern(sess, hs, start, dst, secret))
+        default:
+            packet.ForwardLogin(sess, hs, start, dst)
+        }
+        return
+    case manager.StateStopping:
+        respond(cfg.Messages.Stopping, cfg.Banners.Stopping, "red")
+        return
     }
 
-    wg.Wait()
+    respond(cfg.Messages.Obscure, cfg.Banners.Obscure, "gray")
 
 }
 
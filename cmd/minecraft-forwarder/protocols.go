@@ -0,0 +1,85 @@
+package main
+
+import (
+    "fmt"
+
+    "github.com/hjjg200/minecraft-forwarder/pkg/packet"
+)
+
+// Protocols
+// Clients outside of a server's range are turned away before the server
+// is started, as the backend would only reject them after booting
+type protocolRange struct {
+    min, max int32 // 0 leaves the end open
+}
+
+// protocols returns the configured range, or the protocol of the cached
+// status of the key if it is to be learned; false means any is accepted
+func(scfg ServerConfig) protocols(statusKey string) (protocolRange, bool) {
+
+    if scfg.MinProtocol != 0 || scfg.MaxProtocol != 0 {
+        return protocolRange{scfg.MinProtocol, scfg.MaxProtocol}, true
+    }
+
+    if scfg.LearnProtocol {
+        cached, ok := cachedResponse(statusKey)
+        if ok && cached.Version.Protocol > 0 {
+            p := int32(cached.Version.Protocol)
+            return protocolRange{p, p}, true
+        }
+    }
+
+    return protocolRange{}, false
+
+}
+
+func(pr protocolRange) accepts(protocol int32) bool {
+    return (pr.min == 0 || protocol >= pr.min) && (pr.max == 0 || protocol <= pr.max)
+}
+
+// String names the releases of the range, e.g. 1.20.5-1.21.1
+func(pr protocolRange) String() string {
+
+    release := func(protocol int32, last bool) string {
+        v, ok := packet.LookupVersion(protocol)
+        if !ok {
+            return fmt.Sprintf("protocol %d", protocol)
+        }
+        if last {
+            return v.Releases[len(v.Releases) - 1]
+        }
+        return v.Releases[0]
+    }
+
+    switch {
+    case pr.min == pr.max:
+        if name := packet.VersionName(pr.min); name != "" {
+            return name
+        }
+        return release(pr.min, false)
+    case pr.max == 0:
+        return release(pr.min, false) + " or later"
+    case pr.min == 0:
+        return release(pr.max, true) + " or earlier"
+    }
+
+    return release(pr.min, false) + "-" + release(pr.max, true)
+
+}
+
+// incompatible is the version shown to a client outside of the range, its
+// protocol being the nearest bound so that the client marks it as
+// incompatible and shows the name
+func(pr protocolRange) incompatible(protocol int32) packet.VersionStruct {
+
+    bound := pr.max
+    if bound == 0 || pr.min != 0 && protocol < pr.min {
+        bound = pr.min
+    }
+
+    return packet.VersionStruct{
+        Name: pr.String(),
+        Protocol: int(bound),
+    }
+
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+    "io"
+    "net"
+    "testing"
+    "time"
+
+    "github.com/hjjg200/minecraft-forwarder/pkg/manager"
+    "github.com/hjjg200/minecraft-forwarder/pkg/packet"
+)
+
+func TestProtocolRange(t *testing.T) {
+
+    samples := []struct {
+        pr protocolRange
+        name string
+        accepted, rejected []int32
+    }{
+        {protocolRange{0, 0}, "", []int32{4, 767, 9999}, nil},
+        {protocolRange{766, 0}, "1.20.5 or later", []int32{766, 767, 9999}, []int32{4, 765}},
+        {protocolRange{0, 767}, "1.21.1 or earlier", []int32{4, 766, 767}, []int32{768}},
+        {protocolRange{766, 767}, "1.20.5-1.21.1", []int32{766, 767}, []int32{765, 768}},
+        {protocolRange{767, 767}, "1.21/1.21.1", []int32{767}, []int32{766, 768}},
+        {protocolRange{9999, 9999}, "protocol 9999", []int32{9999}, []int32{767}},
+        {protocolRange{9999, 0}, "protocol 9999 or later", []int32{9999}, []int32{767}},
+    }
+
+    for _, sample := range samples {
+        if sample.name != "" && sample.pr.String() != sample.name {
+            t.Errorf("%v != %s", sample.pr, sample.name)
+        }
+        for _, protocol := range sample.accepted {
+            if !sample.pr.accepts(protocol) {
+                t.Errorf("%s rejects %d", sample.name, protocol)
+            }
+        }
+        for _, protocol := range sample.rejected {
+            if sample.pr.accepts(protocol) {
+                t.Errorf("%s accepts %d", sample.name, protocol)
+            }
+        }
+    }
+
+}
+
+func TestProtocolIncompatible(t *testing.T) {
+
+    // The nearest bound is shown
+    samples := []struct {
+        pr protocolRange
+        protocol int32
+        expected int
+    }{
+        {protocolRange{766, 767}, 765, 766},
+        {protocolRange{766, 767}, 768, 767},
+        {protocolRange{766, 0}, 765, 766},
+        {protocolRange{0, 767}, 768, 767},
+    }
+
+    for _, sample := range samples {
+        v := sample.pr.incompatible(sample.protocol)
+        if v.Protocol != sample.expected || v.Name != sample.pr.String() {
+            t.Errorf("%v of %d: %+v", sample.pr, sample.protocol, v)
+        }
+    }
+
+}
+
+func TestLearnProtocol(t *testing.T) {
+
+    server := ServerConfig{Name: "lobby", Port: 25565, LearnProtocol: true}
+    key := server.statusKey("127.0.0.1:25566")
+
+    // Nothing to learn from yet
+    if _, limited := server.protocols(key); limited {
+        t.Error("Limited without a cached status")
+    }
+
+    statuses.Lock()
+    statuses.entries[key] = cachedStatus{packet.Response{Version: packet.VersionStruct{Name: "1.21.1", Protocol: 767}}, time.Now()}
+    statuses.Unlock()
+    defer func() {
+        statuses.Lock()
+        delete(statuses.entries, key)
+        statuses.Unlock()
+    }()
+
+    pr, limited := server.protocols(key)
+    if !limited || pr != (protocolRange{767, 767}) {
+        t.Errorf("Learned %v %v", pr, limited)
+    }
+
+    // A configured range comes first
+    server.MinProtocol = 766
+    pr, limited = server.protocols(key)
+    if !limited || pr != (protocolRange{766, 0}) {
+        t.Errorf("Configured %v %v", pr, limited)
+    }
+
+}
+
+// loginServer routes lobby to a stopped manager that counts its starts
+func loginServer(t *testing.T, server ServerConfig) *adminManager {
+
+    isolateCurrent(t)
+
+    cfg := Config{
+        Servers: []ServerConfig{server},
+        Messages: DefaultConfig.Messages,
+        Banners: DefaultConfig.Banners,
+    }
+    if err := buildRoutes(&cfg); err != nil {
+        t.Fatal(err)
+    }
+    if err := compileMessages(&cfg); err != nil {
+        t.Fatal(err)
+    }
+
+    am := &adminManager{state: manager.StateStopped}
+    current.Lock()
+    current.config = cfg
+    current.managers = map[string] manager.Manager{server.uuid(): am}
+    current.Unlock()
+
+    return am
+
+}
+
+// login logs in with the protocol and returns what the client was sent
+func login(t *testing.T, protocol int32) []byte {
+
+    client, src := net.Pipe()
+    defer client.Close()
+
+    hs := packet.Handshake{Protocol: protocol, Address: "lobby", Port: 25565, NextState: packet.StateLogin}
+    go handle(src, hs)
+
+    client.SetDeadline(time.Now().Add(5 * time.Second))
+    _, err := client.Write(packet.LoginStart{Name: "Steve"}.Bytes())
+    if err != nil {
+        t.Fatal(err)
+    }
+    p, err := io.ReadAll(client)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    return p
+
+}
+
+func TestLoginOutOfRange(t *testing.T) {
+
+    // 1.8 has no limbo, so a start is answered right away
+    am := loginServer(t, ServerConfig{Name: "lobby", Port: 25565, MinProtocol: 47, MaxProtocol: 47})
+
+    if p := login(t, 767); len(p) == 0 {
+        t.Error("Nothing was sent")
+    }
+    if am.starts != 0 {
+        t.Fatal("Out of range login started the server")
+    }
+
+    login(t, 47)
+    if am.starts != 1 {
+        t.Error("Login in range did not start the server")
+    }
+
+}
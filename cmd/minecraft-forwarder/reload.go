@@ -0,0 +1,405 @@
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "io/ioutil"
+    "net"
+    "reflect"
+    "sync"
+    "time"
+
+    "github.com/hjjg200/minecraft-forwarder/pkg/manager"
+    "github.com/hjjg200/minecraft-forwarder/pkg/packet"
+
+    "github.com/hjjg200/go-jsoncfg"
+)
+
+// Runtime
+// What the current config was turned into. Maps are replaced, never
+// modified, so snapshots can be used without holding the lock.
+type listener struct {
+    net.Listener
+    trusted []string
+}
+
+var current = struct {
+    sync.RWMutex
+    config Config
+    servers map[string] ServerConfig // by uuid
+    managers map[string] manager.Manager
+    watchers map[string] *manager.IdleWatcher
+    listeners map[string] *listener // by address
+}{
+    servers: make(map[string] ServerConfig),
+    managers: make(map[string] manager.Manager),
+    watchers: make(map[string] *manager.IdleWatcher),
+    listeners: make(map[string] *listener),
+}
+
+func snapshot() (Config, map[string] manager.Manager) {
+    current.RLock()
+    defer current.RUnlock()
+    return current.config, current.managers
+}
+
+func readConfig(parser *jsoncfg.Parser, path string) (Config, error) {
+
+    var cfg Config
+
+    data, err := ioutil.ReadFile(path)
+    if err != nil {
+        return cfg, err
+    }
+
+    err = parser.Parse(data, &cfg)
+    if err != nil {
+        return cfg, err
+    }
+
+    err = validate(cfg)
+    if err != nil {
+        return cfg, err
+    }
+
+    err = buildRoutes(&cfg)
+    if err != nil {
+        return cfg, err
+    }
+
+    err = loadFavicons(&cfg)
+    if err != nil {
+        return cfg, err
+    }
+
+    return cfg, compileMessages(&cfg)
+
+}
+
+// forwardBlocks returns the forward block of a server, or the blocks of
+// its backends when it has a list of them
+func forwardBlocks(server ServerConfig) ([]map[string] interface{}, error) {
+
+    var list []interface{}
+    switch forward := server.Forward.(type) {
+    case map[string] interface{}:
+        list = []interface{}{forward}
+    case []interface{}:
+        list = forward
+    }
+    if len(list) == 0 {
+        return nil, fmt.Errorf("Server %s has no forward block", server.Name)
+    }
+
+    blocks := make([]map[string] interface{}, len(list))
+    for i, each := range list {
+        forward, ok := each.(map[string] interface{})
+        if !ok {
+            return nil, fmt.Errorf("Server %s has no forward block", server.Name)
+        }
+        if _, ok := forward["type"].(string); !ok {
+            return nil, fmt.Errorf("Server %s has no forward type", server.Name)
+        }
+        blocks[i] = forward
+    }
+
+    return blocks, nil
+
+}
+
+func validate(cfg Config) error {
+
+    if len(cfg.Listen) == 0 {
+        return fmt.Errorf("No listen address")
+    }
+    for addr, cidrs := range cfg.AcceptProxy {
+        _, err := packet.ParseCIDRs(cidrs)
+        if err != nil {
+            return fmt.Errorf("Trusted proxies of %s: %v", addr, err)
+        }
+    }
+
+    uuids := make(map[string] bool)
+    for _, server := range cfg.Servers {
+        if server.Name == "" {
+            return fmt.Errorf("Server without a name")
+        }
+        if uuids[server.uuid()] {
+            return fmt.Errorf("Server %s is configured twice", server.uuid())
+        }
+        uuids[server.uuid()] = true
+
+        _, err := forwardBlocks(server)
+        if err != nil {
+            return err
+        }
+        switch server.Balance {
+        case "", manager.PolicyRoundRobin, manager.PolicyLeastConnections,
+            manager.PolicyLowestPing, manager.PolicyFillFirst:
+        default:
+            return fmt.Errorf("Unknown balance policy %s of server %s", server.Balance, server.Name)
+        }
+
+        if server.expandsBackend() && len(server.BackendHosts) == 0 {
+            return fmt.Errorf("Backend %s of server %s has captures but no backend hosts", server.Backend, server.Name)
+        }
+
+        switch server.Forwarding {
+        case "", "legacy", "modern":
+        default:
+            return fmt.Errorf("Unknown forwarding mode %s of server %s", server.Forwarding, server.Name)
+        }
+        if server.Forwarding == "modern" && server.ForwardingSecret == "" {
+            return fmt.Errorf("Modern forwarding of server %s has no secret", server.Name)
+        }
+        if server.ProxyProtocol < 0 || server.ProxyProtocol > 2 {
+            return fmt.Errorf("Unknown PROXY protocol version %d of server %s", server.ProxyProtocol, server.Name)
+        }
+        if server.MinProtocol < 0 || server.MaxProtocol < 0 ||
+            server.MaxProtocol != 0 && server.MinProtocol > server.MaxProtocol {
+            return fmt.Errorf("Invalid protocol range %d-%d of server %s", server.MinProtocol, server.MaxProtocol, server.Name)
+        }
+    }
+
+    return nil
+
+}
+
+func newManager(server ServerConfig) (manager.Manager, error) {
+
+    blocks, err := forwardBlocks(server)
+    if err != nil {
+        return nil, err
+    }
+
+    members := make([]manager.Manager, len(blocks))
+    for i, forward := range blocks {
+        members[i], err = newForwardManager(forward)
+        if err != nil {
+            for _, each := range members[:i] {
+                manager.Close(each)
+            }
+            return nil, fmt.Errorf("Server %s: %v", server.Name, err)
+        }
+    }
+
+    m := members[0]
+    if _, ok := server.Forward.([]interface{}); ok {
+        m, err = manager.NewPoolManager(members, server.Balance)
+        if err != nil {
+            return nil, fmt.Errorf("Server %s: %v", server.Name, err)
+        }
+    }
+    manager.SetProxyProtocol(m, server.ProxyProtocol)
+
+    if server.RCON.Password != "" {
+        m = manager.NewGracefulManager(m, server.RCON)
+    }
+
+    return newInstrumentedManager(m, server), nil
+
+}
+
+func newForwardManager(forward map[string] interface{}) (manager.Manager, error) {
+
+    typ := forward["type"].(string)
+    data, err := json.Marshal(forward)
+    if err != nil {
+        return nil, err
+    }
+
+    var m manager.Manager
+    switch typ {
+    case "nop":
+        m = manager.NewNopManager()
+    case "ec2":
+        m, err = manager.NewEC2ManagerJson(data)
+    case "docker":
+        m, err = manager.NewDockerManagerJson(data)
+    case "process":
+        m, err = manager.NewProcessManagerJson(data)
+    case "static":
+        m, err = manager.NewStaticManagerJson(data)
+    case "wol":
+        m, err = manager.NewWOLManagerJson(data)
+    default:
+        err = fmt.Errorf("Unknown server forward type %s", typ)
+    }
+
+    return m, err
+
+}
+
+// sameManager reports whether the manager of old can be kept for neu
+func sameManager(old, neu ServerConfig) bool {
+    return reflect.DeepEqual(old.Forward, neu.Forward) &&
+        old.Balance == neu.Balance &&
+        old.ProxyProtocol == neu.ProxyProtocol &&
+        reflect.DeepEqual(old.RCON, neu.RCON)
+}
+
+// apply switches to the config, rebuilding only the managers and listeners
+// that changed. Live sessions keep running either way.
+func apply(cfg Config) error {
+
+    current.Lock()
+    defer current.Unlock()
+
+    servers := make(map[string] ServerConfig)
+    for _, server := range cfg.Servers {
+        servers[server.uuid()] = server
+    }
+
+    // The config is refused while a manager that is replaced or removed
+    // would leave its server orphaned
+    replaced := make([]manager.Manager, 0)
+    for uuid, old := range current.servers {
+        server, ok := servers[uuid]
+        if ok && sameManager(old, server) {
+            continue
+        }
+        err := manager.Closable(current.managers[uuid])
+        if err != nil {
+            return fmt.Errorf("Server %s cannot be replaced: %v", old.Name, err)
+        }
+        replaced = append(replaced, current.managers[uuid])
+    }
+
+    // Managers, built before anything is replaced
+    managers := make(map[string] manager.Manager)
+    discard := func() {
+        for uuid, m := range managers {
+            if m != current.managers[uuid] {
+                manager.Close(m)
+            }
+        }
+    }
+    for uuid, server := range servers {
+        old, ok := current.servers[uuid]
+        if ok && sameManager(old, server) {
+            managers[uuid] = current.managers[uuid]
+            continue
+        }
+
+        m, err := newManager(server)
+        if err != nil {
+            discard()
+            return err
+        }
+        managers[uuid] = m
+    }
+
+    // Listeners
+    listeners := make(map[string] *listener)
+    opened := make([]*listener, 0)
+    for _, addr := range cfg.Listen {
+        trusted := cfg.AcceptProxy[addr]
+        old, ok := current.listeners[addr]
+        if ok && sameStrings(old.trusted, trusted) {
+            listeners[addr] = old
+            continue
+        }
+        if ok {
+            // Same address with other proxies, reopened below
+            continue
+        }
+
+        ln, err := net.Listen("tcp", addr)
+        if err != nil {
+            for _, each := range opened {
+                each.Close()
+            }
+            discard()
+            return err
+        }
+        listeners[addr] = &listener{ln, trusted}
+        opened = append(opened, listeners[addr])
+    }
+
+    for addr, old := range current.listeners {
+        if listeners[addr] == old {
+            continue
+        }
+        old.Close()
+        fmt.Println("Stopped listening on", addr)
+
+        if _, ok := listeners[addr]; ok || !contains(cfg.Listen, addr) {
+            continue
+        }
+        ln, err := net.Listen("tcp", addr)
+        if err != nil {
+            fmt.Println("Failed to listen again on", addr, err)
+            continue
+        }
+        listeners[addr] = &listener{ln, cfg.AcceptProxy[addr]}
+        opened = append(opened, listeners[addr])
+    }
+
+    for _, ln := range opened {
+        trustedNets, _ := packet.ParseCIDRs(ln.trusted)
+        go func(ln *listener) {
+            fmt.Println("Listening on", ln.Addr())
+            packet.Serve(ln, trustedNets, packet.HandlerFunc(handle))
+        }(ln)
+    }
+
+    // Idle shutdown
+    watchers := make(map[string] *manager.IdleWatcher)
+    for uuid, server := range servers {
+        old, ok := current.watchers[uuid]
+        if ok && managers[uuid] == current.managers[uuid] &&
+            current.servers[uuid].IdleMinutes == server.IdleMinutes {
+            watchers[uuid] = old
+            continue
+        }
+        if server.IdleMinutes > 0 && !manager.CanStop(managers[uuid]) {
+            fmt.Println("Server", server.Name, "cannot be stopped, its idle shutdown is off")
+        } else if server.IdleMinutes > 0 {
+            limit := time.Duration(server.IdleMinutes) * time.Minute
+            watchers[uuid] = manager.NewIdleWatcher(managers[uuid], limit)
+            watchers[uuid].SetProxyProtocol(server.ProxyProtocol)
+            go watchers[uuid].Run()
+        }
+    }
+    for uuid, old := range current.watchers {
+        if watchers[uuid] != old {
+            old.Close()
+        }
+    }
+
+    for _, m := range replaced {
+        if err := manager.Close(m); err != nil {
+            fmt.Println("Replaced manager was not closed:", err)
+        }
+    }
+
+    current.config = cfg
+    current.servers = servers
+    current.managers = managers
+    current.watchers = watchers
+    current.listeners = listeners
+
+    return nil
+
+}
+
+func sameStrings(a, b []string) bool {
+    if len(a) != len(b) {
+        return false
+    }
+    for i := range a {
+        if a[i] != b[i] {
+            return false
+        }
+    }
+    return true
+}
+
+func contains(strs []string, str string) bool {
+    for _, each := range strs {
+        if each == str {
+            return true
+        }
+    }
+    return false
+}
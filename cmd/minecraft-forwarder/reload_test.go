@@ -0,0 +1,237 @@
+package main
+
+import (
+    "net"
+    "testing"
+
+    "github.com/hjjg200/minecraft-forwarder/pkg/manager"
+)
+
+const testListen = "127.0.0.1:0"
+
+// isolateCurrent gives the test a runtime of its own, whose listeners and
+// watchers are closed afterwards
+func isolateCurrent(t *testing.T) {
+
+    current.Lock()
+    config, servers, managers := current.config, current.servers, current.managers
+    watchers, listeners := current.watchers, current.listeners
+    current.servers = make(map[string] ServerConfig)
+    current.managers = make(map[string] manager.Manager)
+    current.watchers = make(map[string] *manager.IdleWatcher)
+    current.listeners = make(map[string] *listener)
+    current.Unlock()
+
+    t.Cleanup(func() {
+        current.Lock()
+        defer current.Unlock()
+        for _, ln := range current.listeners {
+            ln.Close()
+        }
+        for _, iw := range current.watchers {
+            iw.Close()
+        }
+        current.config, current.servers, current.managers = config, servers, managers
+        current.watchers, current.listeners = watchers, listeners
+    })
+
+}
+
+// processServer is a server with a process manager, which is left alone
+// as it is never started
+func processServer(name, addr string) ServerConfig {
+    return ServerConfig{
+        Name: name,
+        Port: 25565,
+        Forward: map[string] interface{}{
+            "type": "process",
+            "command": []interface{}{"true"},
+            "address": addr,
+        },
+    }
+}
+
+func testConfig(servers ...ServerConfig) Config {
+    return Config{
+        Listen: []string{testListen},
+        AcceptProxy: map[string] []string{},
+        Servers: servers,
+    }
+}
+
+func runtimeOf(uuid string) (manager.Manager, *manager.IdleWatcher, *listener) {
+    current.RLock()
+    defer current.RUnlock()
+    return current.managers[uuid], current.watchers[uuid], current.listeners[testListen]
+}
+
+func TestApplyKeeps(t *testing.T) {
+
+    isolateCurrent(t)
+
+    lobby := processServer("lobby", "127.0.0.1:25566")
+    uuid := lobby.uuid()
+    if err := apply(testConfig(lobby)); err != nil {
+        t.Fatal(err)
+    }
+    m, _, ln := runtimeOf(uuid)
+    if m == nil || ln == nil {
+        t.Fatal("Nothing was applied")
+    }
+
+    // The same config keeps everything
+    if err := apply(testConfig(lobby)); err != nil {
+        t.Fatal(err)
+    }
+    if m2, _, ln2 := runtimeOf(uuid); m2 != m || ln2 != ln {
+        t.Error("Unchanged manager or listener was replaced")
+    }
+
+    // A backend of its own keeps the manager too
+    lobby.Backend = "127.0.0.1:25567"
+    if err := apply(testConfig(lobby)); err != nil {
+        t.Fatal(err)
+    }
+    if m2, _, _ := runtimeOf(uuid); m2 != m {
+        t.Error("Manager was replaced for its backend")
+    }
+
+    // Another forward block replaces it
+    lobby = processServer("lobby", "127.0.0.1:25568")
+    if err := apply(testConfig(lobby)); err != nil {
+        t.Fatal(err)
+    }
+    m2, _, ln2 := runtimeOf(uuid)
+    if m2 == m || m2.Addr() != "127.0.0.1:25568" {
+        t.Error("Changed manager was kept")
+    }
+    if ln2 != ln {
+        t.Error("Listener was replaced")
+    }
+
+    // Other trusted proxies reopen the listener
+    cfg := testConfig(lobby)
+    cfg.AcceptProxy[testListen] = []string{"10.0.0.0/8"}
+    if err := apply(cfg); err != nil {
+        t.Fatal(err)
+    }
+    m3, _, ln3 := runtimeOf(uuid)
+    if m3 != m2 || ln3 == nil || ln3 == ln || !sameStrings(ln3.trusted, cfg.AcceptProxy[testListen]) {
+        t.Error("Listener was not reopened", ln3)
+    }
+
+    // Removed servers go
+    if err := apply(testConfig()); err != nil {
+        t.Fatal(err)
+    }
+    if m4, _, _ := runtimeOf(uuid); m4 != nil {
+        t.Error("Removed manager was kept")
+    }
+
+}
+
+func TestApplyWatchers(t *testing.T) {
+
+    isolateCurrent(t)
+
+    lobby := processServer("lobby", "127.0.0.1:25566")
+    lobby.IdleMinutes = 5
+    uuid := lobby.uuid()
+    if err := apply(testConfig(lobby)); err != nil {
+        t.Fatal(err)
+    }
+    _, iw, _ := runtimeOf(uuid)
+    if iw == nil {
+        t.Fatal("No idle watcher")
+    }
+
+    if err := apply(testConfig(lobby)); err != nil {
+        t.Fatal(err)
+    }
+    if _, iw2, _ := runtimeOf(uuid); iw2 != iw {
+        t.Error("Unchanged watcher was replaced")
+    }
+
+    lobby.IdleMinutes = 10
+    if err := apply(testConfig(lobby)); err != nil {
+        t.Fatal(err)
+    }
+    _, iw2, _ := runtimeOf(uuid)
+    if iw2 == nil || iw2 == iw {
+        t.Error("Watcher was not replaced for its limit")
+    }
+
+    lobby.IdleMinutes = 0
+    if err := apply(testConfig(lobby)); err != nil {
+        t.Fatal(err)
+    }
+    if _, iw3, _ := runtimeOf(uuid); iw3 != nil {
+        t.Error("Disabled watcher was kept")
+    }
+
+    // Servers that cannot be stopped are not watched
+    lobby.IdleMinutes = 5
+    lobby.Forward = map[string] interface{}{
+        "type": "static",
+        "backends": []interface{}{"127.0.0.1:25566"},
+    }
+    if err := apply(testConfig(lobby)); err != nil {
+        t.Fatal(err)
+    }
+    if _, iw4, _ := runtimeOf(uuid); iw4 != nil {
+        t.Error("Static server is watched")
+    }
+
+}
+
+func TestApplyFails(t *testing.T) {
+
+    isolateCurrent(t)
+
+    lobby := processServer("lobby", "127.0.0.1:25566")
+    lobby.IdleMinutes = 5
+    uuid := lobby.uuid()
+    good := testConfig(lobby)
+    if err := apply(good); err != nil {
+        t.Fatal(err)
+    }
+    m, iw, ln := runtimeOf(uuid)
+
+    expectIntact := func() {
+        t.Helper()
+        current.RLock()
+        listen := current.config.Listen
+        current.RUnlock()
+        if !sameStrings(listen, good.Listen) {
+            t.Error("Config was replaced", listen)
+        }
+        if m2, iw2, ln2 := runtimeOf(uuid); m2 != m || iw2 != iw || ln2 != ln {
+            t.Error("Runtime was replaced")
+        }
+    }
+
+    // A listener that fails, with the server kept
+    bad := testConfig(lobby)
+    bad.Listen = append(bad.Listen, "256.0.0.1:1")
+    if err := apply(bad); err == nil {
+        t.Error("Bad listen address was applied")
+    }
+    expectIntact()
+
+    // A manager that fails, with the server replaced and another added
+    hub := processServer("hub", "127.0.0.1:25567")
+    hub.Forward = map[string] interface{}{"type": "unknown"}
+    bad = testConfig(processServer("lobby", "127.0.0.1:25568"), hub)
+    if err := apply(bad); err == nil {
+        t.Error("Unknown forward type was applied")
+    }
+    expectIntact()
+
+    // The kept listener still accepts
+    conn, err := net.Dial("tcp", ln.Addr().String())
+    if err != nil {
+        t.Fatal(err)
+    }
+    conn.Close()
+
+}
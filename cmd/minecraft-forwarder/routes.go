@@ -0,0 +1,102 @@
+package main
+
+import (
+    "fmt"
+    "net"
+    "strings"
+    "time"
+
+    "github.com/hjjg200/minecraft-forwarder/pkg/route"
+)
+
+const backendDialTimeout = 5 * time.Second
+
+// buildRoutes makes the routing table of the names and aliases of the
+// servers, which may be wildcards or regexes, see route.Table
+func buildRoutes(cfg *Config) error {
+
+    cfg.routes = route.NewTable()
+    cfg.servers = make(map[string] *ServerConfig)
+
+    for i := range cfg.Servers {
+        server := &cfg.Servers[i]
+        uuid := server.uuid()
+        cfg.servers[uuid] = server
+
+        for _, pattern := range append([]string{server.Name}, server.Aliases...) {
+            err := cfg.routes.Add(pattern, uuid)
+            if err != nil {
+                return fmt.Errorf("Server %s: %v", server.Name, err)
+            }
+        }
+    }
+
+    if cfg.Default != "" {
+        for _, server := range cfg.Servers {
+            if server.Name == cfg.Default {
+                cfg.routes.SetDefault(server.uuid())
+                return nil
+            }
+        }
+        return fmt.Errorf("Default server %s is not configured", cfg.Default)
+    }
+
+    return nil
+
+}
+
+// route finds the server of a hostname
+func(cfg Config) route(hostname string) (*ServerConfig, route.Match, bool) {
+    m, ok := cfg.routes.Lookup(hostname)
+    if !ok {
+        return nil, m, false
+    }
+    return cfg.servers[m.Key], m, true
+}
+
+// backendAddr is the address of the backend, which is the manager's unless
+// the server sets one, possibly with the captures of its route. Those come
+// from the client, so the host they make must be one of BackendHosts.
+func(scfg ServerConfig) backendAddr(m route.Match, managerAddr string) (string, error) {
+
+    if scfg.Backend == "" {
+        return managerAddr, nil
+    }
+    if !scfg.expandsBackend() {
+        return scfg.Backend, nil
+    }
+
+    addr := m.Expand(scfg.Backend)
+    host, _, err := net.SplitHostPort(addr)
+    if err != nil {
+        return "", fmt.Errorf("Backend %s: %v", addr, err)
+    }
+    host = route.Normalize(host)
+    for _, allowed := range scfg.BackendHosts {
+        allowed = route.Normalize(allowed)
+        if host == allowed ||
+            strings.HasPrefix(allowed, "*.") && strings.HasSuffix(host, allowed[1:]) {
+            return addr, nil
+        }
+    }
+
+    return "", fmt.Errorf("Backend %s is not among the backend hosts", addr)
+
+}
+
+func(scfg ServerConfig) expandsBackend() bool {
+    return strings.Contains(scfg.Backend, "$")
+}
+
+// statusKey is the key of the cached status of a backend, which is the
+// server's unless its backend is named by the client
+func(scfg ServerConfig) statusKey(addr string) string {
+    if scfg.expandsBackend() {
+        return scfg.uuid() + "@" + addr
+    }
+    return scfg.uuid()
+}
+
+func dialBackend(addr string) (net.Conn, error) {
+    return net.DialTimeout("tcp", addr, backendDialTimeout)
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+    "net"
+    "sort"
+    "sync"
+    "sync/atomic"
+    "time"
+
+    "github.com/hjjg200/minecraft-forwarder/pkg/metrics"
+)
+
+// Session
+// Forwarded connection, counting the bytes that go through it
+type Session struct {
+    net.Conn
+    id uint64
+    Server string
+    Player string
+    Started time.Time
+    up int64 // client to backend
+    down int64 // backend to client
+    upBytes *metrics.Value
+    downBytes *metrics.Value
+}
+
+type SessionInfo struct {
+    ID uint64 `json:"id"`
+    Server string `json:"server"`
+    Player string `json:"player"`
+    Client string `json:"client"`
+    Up int64 `json:"bytesUp"`
+    Down int64 `json:"bytesDown"`
+    Duration float64 `json:"duration"` // unit: seconds
+}
+
+var sessions = struct {
+    sync.Mutex
+    next uint64
+    m map[uint64] *Session
+}{
+    m: make(map[uint64] *Session),
+}
+
+func openSession(server, player string, conn net.Conn) *Session {
+
+    sessions.Lock()
+    defer sessions.Unlock()
+
+    sessions.next++
+    sess := &Session{
+        Conn: conn,
+        id: sessions.next,
+        Server: server,
+        Player: player,
+        Started: time.Now(),
+        upBytes: metrics.Bytes.With(server, "up"),
+        downBytes: metrics.Bytes.With(server, "down"),
+    }
+    sessions.m[sess.id] = sess
+    metrics.Sessions.Inc(server)
+
+    return sess
+
+}
+
+func(sess *Session) Read(p []byte) (int, error) {
+    n, err := sess.Conn.Read(p)
+    sess.countUp(n)
+    return n, err
+}
+
+// countUp counts bytes from the client, including those read before the
+// session was opened, such as the handshake, which are replayed
+func(sess *Session) countUp(n int) {
+    atomic.AddInt64(&sess.up, int64(n))
+    sess.upBytes.Add(float64(n))
+}
+
+func(sess *Session) Write(p []byte) (int, error) {
+    n, err := sess.Conn.Write(p)
+    atomic.AddInt64(&sess.down, int64(n))
+    sess.downBytes.Add(float64(n))
+    return n, err
+}
+
+func(sess *Session) Close() error {
+
+    sessions.Lock()
+    if _, ok := sessions.m[sess.id]; ok {
+        delete(sessions.m, sess.id)
+        metrics.Sessions.Dec(sess.Server)
+    }
+    sessions.Unlock()
+
+    return sess.Conn.Close()
+
+}
+
+func(sess *Session) Info() SessionInfo {
+    return SessionInfo{
+        ID: sess.id,
+        Server: sess.Server,
+        Player: sess.Player,
+        Client: sess.RemoteAddr().String(),
+        Up: atomic.LoadInt64(&sess.up),
+        Down: atomic.LoadInt64(&sess.down),
+        Duration: time.Since(sess.Started).Seconds(),
+    }
+}
+
+func sessionInfos() []SessionInfo {
+
+    sessions.Lock()
+    defer sessions.Unlock()
+
+    infos := make([]SessionInfo, 0, len(sessions.m))
+    for _, sess := range sessions.m {
+        infos = append(infos, sess.Info())
+    }
+    sort.Slice(infos, func(i, j int) bool {
+        return infos[i].ID < infos[j].ID
+    })
+
+    return infos
+
+}
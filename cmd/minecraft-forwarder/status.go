@@ -0,0 +1,165 @@
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "io/ioutil"
+    "os"
+    "sync"
+    "time"
+
+    "github.com/hjjg200/minecraft-forwarder/pkg/manager"
+    "github.com/hjjg200/minecraft-forwarder/pkg/packet"
+    "github.com/hjjg200/minecraft-forwarder/pkg/route"
+)
+
+// Status cache
+// Last real status response of each server, shown while it is down
+type BannerConfig struct {
+    Stopped string `json:"stopped"`
+    Pending string `json:"pending"`
+    Stopping string `json:"stopping"`
+    Obscure string `json:"obscure"`
+    Offline string `json:"offline"`
+}
+
+type cachedStatus struct {
+    Response packet.Response `json:"response"`
+    Time time.Time `json:"time"`
+}
+
+const (
+    statusRefresh = time.Minute
+    statusTimeout = 5 * time.Second
+)
+
+var statuses = struct {
+    sync.Mutex
+    path string
+    entries map[string] cachedStatus // by status key, see statusKey
+    fetching map[string] bool
+    running map[string] bool // by server uuid, as of the last state
+}{
+    entries: make(map[string] cachedStatus),
+    fetching: make(map[string] bool),
+    running: make(map[string] bool),
+}
+
+// loadStatuses reads the cache file, which is then kept up to date. A file
+// that cannot be decoded is only a lost cache and is overwritten later.
+func loadStatuses(path string) error {
+
+    statuses.Lock()
+    defer statuses.Unlock()
+
+    statuses.path = path
+    if path == "" {
+        return nil
+    }
+
+    data, err := ioutil.ReadFile(path)
+    if os.IsNotExist(err) {
+        return nil
+    } else if err != nil {
+        return err
+    }
+
+    entries := make(map[string] cachedStatus)
+    err = json.Unmarshal(data, &entries)
+    if err != nil {
+        fmt.Println("Status cache", path, "is discarded:", err)
+        return nil
+    }
+    statuses.entries = entries
+
+    return nil
+
+}
+
+// saveStatuses expects the lock to be held
+func saveStatuses() error {
+
+    if statuses.path == "" {
+        return nil
+    }
+
+    data, err := json.Marshal(statuses.entries)
+    if err != nil {
+        return err
+    }
+
+    tmp := statuses.path + ".tmp"
+    err = ioutil.WriteFile(tmp, data, 0600)
+    if err != nil {
+        return err
+    }
+
+    return os.Rename(tmp, statuses.path)
+
+}
+
+func cachedResponse(key string) (packet.Response, bool) {
+    statuses.Lock()
+    defer statuses.Unlock()
+    entry, ok := statuses.entries[key]
+    return entry.Response, ok
+}
+
+// refreshStatus fetches the status of a running server in the background,
+// at most once per statusRefresh, with a PROXY header of the given version
+func refreshStatus(key, addr string, version int) {
+
+    statuses.Lock()
+    defer statuses.Unlock()
+
+    entry, ok := statuses.entries[key]
+    if statuses.fetching[key] || (ok && time.Since(entry.Time) < statusRefresh) {
+        return
+    }
+    statuses.fetching[key] = true
+
+    go func() {
+        rsp, err := manager.ProbeStatus(addr, version, statusTimeout)
+
+        statuses.Lock()
+        defer statuses.Unlock()
+
+        delete(statuses.fetching, key)
+        if err != nil {
+            fmt.Println("Status refresh failed:", err)
+            return
+        }
+
+        statuses.entries[key] = cachedStatus{rsp, time.Now()}
+        err = saveStatuses()
+        if err != nil {
+            fmt.Println("Status cache save failed:", err)
+        }
+    }()
+
+}
+
+// seedStatus refreshes the status of a server as soon as it is seen
+// running, rather than once a client reaches it. Backends named by clients
+// are left to them.
+func seedStatus(server ServerConfig, m manager.Manager, state int) {
+
+    uuid := server.uuid()
+    running := state == manager.StateRunning
+
+    statuses.Lock()
+    seen := statuses.running[uuid]
+    statuses.running[uuid] = running
+    statuses.Unlock()
+
+    if !running || seen || server.expandsBackend() {
+        return
+    }
+
+    addr, err := server.backendAddr(route.Match{}, m.Addr())
+    if err != nil {
+        return
+    }
+    refreshStatus(server.statusKey(addr), addr, server.ProxyProtocol)
+
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+    "io/ioutil"
+    "net"
+    "path/filepath"
+    "sync/atomic"
+    "testing"
+    "time"
+
+    "github.com/hjjg200/minecraft-forwarder/pkg/packet"
+)
+
+// isolateStatuses gives the test an empty status cache of its own
+func isolateStatuses(t *testing.T) {
+
+    statuses.Lock()
+    path, entries, fetching := statuses.path, statuses.entries, statuses.fetching
+    statuses.path = ""
+    statuses.entries = make(map[string] cachedStatus)
+    statuses.fetching = make(map[string] bool)
+    statuses.Unlock()
+
+    t.Cleanup(func() {
+        statuses.Lock()
+        defer statuses.Unlock()
+        statuses.path, statuses.entries, statuses.fetching = path, entries, fetching
+    })
+
+}
+
+func TestStatusCacheFile(t *testing.T) {
+
+    isolateStatuses(t)
+    path := filepath.Join(t.TempDir(), "status-cache.json")
+
+    // Missing
+    if err := loadStatuses(path); err != nil {
+        t.Fatal(err)
+    }
+    if _, ok := cachedResponse("lobby:25565"); ok {
+        t.Fatal("Status out of nowhere")
+    }
+
+    rsp := packet.Response{
+        Version: packet.VersionStruct{Name: "1.21.1", Protocol: 767},
+        Players: packet.PlayersStruct{Max: 20, Online: 3},
+        Description: packet.Chat{Text: "A Minecraft Server"},
+    }
+    statuses.Lock()
+    statuses.entries["lobby:25565"] = cachedStatus{rsp, time.Now()}
+    err := saveStatuses()
+    statuses.entries = make(map[string] cachedStatus)
+    statuses.Unlock()
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    // Round trip
+    if err := loadStatuses(path); err != nil {
+        t.Fatal(err)
+    }
+    cached, ok := cachedResponse("lobby:25565")
+    if !ok || cached.Version != rsp.Version || cached.Players.Online != 3 || cached.Description.Text != rsp.Description.Text {
+        t.Errorf("%+v", cached)
+    }
+
+    // Corrupt, which leaves an empty cache
+    if err := ioutil.WriteFile(path, []byte(`{"lobby:25565":`), 0600); err != nil {
+        t.Fatal(err)
+    }
+    statuses.Lock()
+    statuses.entries = make(map[string] cachedStatus)
+    statuses.Unlock()
+    if err := loadStatuses(path); err != nil {
+        t.Fatal(err)
+    }
+    if _, ok := cachedResponse("lobby:25565"); ok {
+        t.Error("Status from a corrupt file")
+    }
+
+}
+
+func TestRefreshStatus(t *testing.T) {
+
+    isolateStatuses(t)
+
+    ln, err := net.Listen("tcp", "127.0.0.1:0")
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer ln.Close()
+
+    var probes int32
+    rsp := packet.Response{Version: packet.VersionStruct{Name: "1.21.1", Protocol: 767}}
+    go packet.Serve(ln, nil, packet.HandlerFunc(func(conn net.Conn, hs packet.Handshake) {
+        atomic.AddInt32(&probes, 1)
+        packet.ServeResponse(conn, hs, rsp)
+    }))
+
+    key, addr := "lobby:25565", ln.Addr().String()
+    wait := func(expected int32) {
+        t.Helper()
+        deadline := time.Now().Add(2 * time.Second)
+        for time.Now().Before(deadline) {
+            statuses.Lock()
+            fetching := statuses.fetching[key]
+            statuses.Unlock()
+            if !fetching {
+                break
+            }
+            time.Sleep(10 * time.Millisecond)
+        }
+        if n := atomic.LoadInt32(&probes); n != expected {
+            t.Fatalf("%d probes, expected %d", n, expected)
+        }
+    }
+
+    // One probe at a time
+    refreshStatus(key, addr, 0)
+    refreshStatus(key, addr, 0)
+    wait(1)
+    if cached, ok := cachedResponse(key); !ok || cached.Version != rsp.Version {
+        t.Fatal("Status was not cached")
+    }
+
+    // None while the status is fresh
+    refreshStatus(key, addr, 0)
+    wait(1)
+
+    statuses.Lock()
+    entry := statuses.entries[key]
+    entry.Time = time.Now().Add(-statusRefresh)
+    statuses.entries[key] = entry
+    statuses.Unlock()
+    refreshStatus(key, addr, 0)
+    wait(2)
+
+}
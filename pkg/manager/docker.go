@@ -0,0 +1,211 @@
+package manager
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "io"
+    "net"
+    "net/http"
+    "net/url"
+    "sync"
+    "time"
+)
+
+// Docker
+// Talks to the Docker Engine API over its unix socket
+type DockerManager struct {
+    SocketPath string `json:"socketPath"`
+    Container string `json:"container"`
+    Address string `json:"address"` // host:port of the minecraft server
+    Timeout int `json:"timeout"` // unit: seconds
+    appState int
+    client *http.Client
+    status string // of the container, e.g. "exited"
+    prober
+    lock sync.Mutex
+}
+
+const (
+    DefaultDockerSocketPath = "/var/run/docker.sock"
+    dockerGrace = 10 * time.Second // on top of the timeout for stopping
+)
+
+func newDockerManager() *DockerManager {
+    return &DockerManager{
+        SocketPath: DefaultDockerSocketPath,
+        Timeout: 10,
+        appState: StateObscure,
+    }
+}
+
+func NewDockerManager(sp, ct, addr string, to int) *DockerManager {
+    d := newDockerManager()
+    d.SocketPath = sp
+    d.Container = ct
+    d.Address = addr
+    d.Timeout = to
+    return d
+}
+
+func NewDockerManagerJson(data []byte) (*DockerManager, error) {
+    d := newDockerManager()
+    return d, json.Unmarshal(data, d)
+}
+
+func(d *DockerManager) httpClient() *http.Client {
+    if d.client == nil {
+        d.client = &http.Client{
+            Transport: &http.Transport{
+                DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+                    var dialer net.Dialer
+                    return dialer.DialContext(ctx, "unix", d.SocketPath)
+                },
+            },
+            Timeout: d.timeout() + dockerGrace,
+        }
+    }
+    return d.client
+}
+
+// do requests the engine and decodes the json response into v if not nil
+func(d *DockerManager) do(method, path string, v interface{}) error {
+
+    req, err := http.NewRequest(method, "http://docker" + path, nil)
+    if err != nil {
+        return err
+    }
+
+    rsp, err := d.httpClient().Do(req)
+    if err != nil {
+        return err
+    }
+    defer rsp.Body.Close()
+
+    switch {
+    case rsp.StatusCode == http.StatusNotModified: // already started or stopped
+        return nil
+    case rsp.StatusCode >= 300:
+        var msg struct {
+            Message string `json:"message"`
+        }
+        json.NewDecoder(rsp.Body).Decode(&msg)
+        return fmt.Errorf("Docker %s %s: %d %s", method, path, rsp.StatusCode, msg.Message)
+    case v != nil:
+        return json.NewDecoder(rsp.Body).Decode(v)
+    }
+
+    io.Copy(io.Discard, rsp.Body)
+    return nil
+
+}
+
+func(d *DockerManager) containerPath(action string) string {
+    return "/containers/" + url.PathEscape(d.Container) + action
+}
+
+func(d *DockerManager) inspect() (string, error) {
+    var info struct {
+        State struct {
+            Status string `json:"Status"`
+        } `json:"State"`
+    }
+    err := d.do("GET", d.containerPath("/json"), &info)
+    return info.State.Status, err
+}
+
+func(d *DockerManager) Addr() string {
+    return d.Address
+}
+
+func(d *DockerManager) Start() error {
+
+    d.lock.Lock()
+    defer d.lock.Unlock()
+
+    status, err := d.inspect()
+    if err != nil {
+        return err
+    }
+
+    action := "/start"
+    if status == "paused" {
+        action = "/unpause"
+    }
+    err = d.do("POST", d.containerPath(action), nil)
+    if err != nil {
+        return err
+    }
+
+    d.appState = StatePending
+    return nil
+
+}
+
+func(d *DockerManager) Stop() error {
+
+    d.lock.Lock()
+    defer d.lock.Unlock()
+
+    path := d.containerPath("/stop") + fmt.Sprintf("?t=%d", d.Timeout)
+    err := d.do("POST", path, nil)
+    if err != nil {
+        return err
+    }
+
+    d.appState = StateStopping
+    return nil
+
+}
+
+func(d *DockerManager) State() (int, error) {
+
+    d.lock.Lock()
+    defer d.lock.Unlock()
+
+    status, err := d.inspect()
+    if err != nil {
+        return StateObscure, err
+    }
+    d.status = status
+
+    switch status {
+    case "created", "exited", "dead", "paused":
+        d.appState = StateStopped
+        return StateStopped, nil
+    case "restarting":
+        return StatePending, nil
+    case "removing":
+        return StateStopping, nil
+    case "running":
+        // Check underlying server
+        conn, err := d.Dial()
+        if err == nil {
+            conn.Close()
+            d.appState = StateRunning
+            return StateRunning, nil
+        }
+        if d.appState == StateRunning || d.appState == StateStopping {
+            d.appState = StateStopping
+            return StateStopping, nil
+        }
+        return StatePending, nil
+    }
+
+    return StateObscure, nil
+
+}
+
+func(d *DockerManager) Describe() string {
+    d.lock.Lock()
+    defer d.lock.Unlock()
+    return d.status
+}
+
+func(d *DockerManager) timeout() time.Duration {
+    return time.Duration(d.Timeout) * time.Second
+}
+
+func(d *DockerManager) Dial() (net.Conn, error) {
+    return d.dial(d.Address, d.timeout())
+}
@@ -0,0 +1,148 @@
+package manager
+
+import (
+    "fmt"
+    "net"
+    "net/http"
+    "net/http/httptest"
+    "path/filepath"
+    "sync"
+    "testing"
+
+    "github.com/hjjg200/minecraft-forwarder/pkg/packet"
+)
+
+// fakeDocker serves a single container over a unix socket
+type fakeDocker struct {
+    status string
+    calls []string
+    lock sync.Mutex
+}
+
+func(fd *fakeDocker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+    fd.lock.Lock()
+    defer fd.lock.Unlock()
+
+    fd.calls = append(fd.calls, r.Method + " " + r.URL.Path)
+
+    switch r.Method + " " + r.URL.Path {
+    case "GET /containers/mc/json":
+        fmt.Fprintf(w, `{"State":{"Status":%q}}`, fd.status)
+    case "POST /containers/mc/start":
+        if fd.status == "running" {
+            w.WriteHeader(http.StatusNotModified)
+            return
+        }
+        fd.status = "running"
+        w.WriteHeader(http.StatusNoContent)
+    case "POST /containers/mc/unpause":
+        fd.status = "running"
+        w.WriteHeader(http.StatusNoContent)
+    case "POST /containers/mc/stop":
+        fd.status = "exited"
+        w.WriteHeader(http.StatusNoContent)
+    default:
+        w.WriteHeader(http.StatusNotFound)
+        fmt.Fprint(w, `{"message":"No such container"}`)
+    }
+
+}
+
+func newFakeDocker(t *testing.T, status string) (*fakeDocker, string) {
+
+    sp := filepath.Join(t.TempDir(), "docker.sock")
+    ln, err := net.Listen("unix", sp)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    fd := &fakeDocker{status: status}
+    srv := httptest.NewUnstartedServer(fd)
+    srv.Listener = ln
+    srv.Start()
+    t.Cleanup(srv.Close)
+
+    return fd, sp
+
+}
+
+// fakeMinecraft answers status pings
+func fakeMinecraft(t *testing.T) string {
+
+    ln, err := net.Listen("tcp", "localhost:0")
+    if err != nil {
+        t.Fatal(err)
+    }
+    addr := ln.Addr().String()
+    ln.Close()
+
+    go packet.ListenAndServe(addr, packet.HandlerFunc(func(conn net.Conn, hs packet.Handshake) {
+        packet.ServeResponse(conn, hs, packet.Response{})
+    }))
+
+    return addr
+
+}
+
+func TestDockerStates(t *testing.T) {
+
+    samples := map[string] int{
+        "created": StateStopped,
+        "exited": StateStopped,
+        "paused": StateStopped,
+        "restarting": StatePending,
+        "removing": StateStopping,
+        "running": StatePending, // nothing listening
+    }
+
+    for status, expected := range samples {
+        _, sp := newFakeDocker(t, status)
+        d := NewDockerManager(sp, "mc", "localhost:1", 1)
+
+        state, err := d.State()
+        t.Logf("%s) %d == %d, %v", status, state, expected, err)
+        if err != nil || state != expected {
+            t.Fail()
+        }
+    }
+
+}
+
+func TestDockerStartStop(t *testing.T) {
+
+    fd, sp := newFakeDocker(t, "exited")
+    d := NewDockerManager(sp, "mc", fakeMinecraft(t), 5)
+
+    if err := d.Start(); err != nil {
+        t.Fatal(err)
+    }
+    state, err := d.State()
+    if err != nil || state != StateRunning {
+        t.Errorf("Expected running, got %d %v", state, err)
+    }
+
+    if err := d.Stop(); err != nil {
+        t.Fatal(err)
+    }
+    state, err = d.State()
+    if err != nil || state != StateStopped {
+        t.Errorf("Expected stopped, got %d %v", state, err)
+    }
+
+    t.Log(fd.calls)
+
+}
+
+func TestDockerMissing(t *testing.T) {
+
+    _, sp := newFakeDocker(t, "running")
+    d := NewDockerManager(sp, "missing", "localhost:1", 1)
+
+    _, err := d.State()
+    t.Log(err)
+    if err == nil {
+        t.Fail()
+    }
+
+}
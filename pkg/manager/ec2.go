@@ -24,6 +24,8 @@ type EC2Manager struct {
     publicDnsName string
     appState int
     appTime time.Time
+    instanceState string // e.g. "stopping"
+    prober
     lock sync.Mutex
 }
 
@@ -125,6 +127,30 @@ func(ec2 *EC2Manager) Start() error {
 
 }
 
+func(ec2 *EC2Manager) Stop() error {
+
+    ec2.lock.Lock()
+    defer ec2.lock.Unlock()
+
+    svc, err := ec2.newService()
+    if err != nil {
+        return err
+    }
+
+    input := &awsec2.StopInstancesInput{
+        InstanceIds: ec2.instanceIds(),
+    }
+    _, err = svc.StopInstances(input)
+    if err != nil {
+        return err
+    }
+
+    ec2.appState = StateStopping
+
+    return nil
+
+}
+
 func(ec2 *EC2Manager) State() (int, error) {
 
     ec2.lock.Lock()
@@ -144,6 +170,9 @@ func(ec2 *EC2Manager) State() (int, error) {
     }
 
     instance := result.Reservations[0].Instances[0]
+    if instance.State.Name != nil {
+        ec2.instanceState = *instance.State.Name
+    }
     // Dns and code
     switch *instance.State.Code {
     case 0: // EC2 pending
@@ -182,10 +211,16 @@ func(ec2 *EC2Manager) State() (int, error) {
 
 }
 
+func(ec2 *EC2Manager) Describe() string {
+    ec2.lock.Lock()
+    defer ec2.lock.Unlock()
+    return ec2.instanceState
+}
+
 func(ec2 *EC2Manager) timeout() time.Duration {
     return time.Duration(ec2.Timeout) * time.Second
 }
 
 func(ec2 *EC2Manager) Dial() (net.Conn, error) {
-    return dialTimeout(ec2.Addr(), ec2.timeout())
+    return ec2.dial(ec2.Addr(), ec2.timeout())
 }
@@ -0,0 +1,166 @@
+package manager
+
+import (
+    "fmt"
+    "net"
+    "time"
+
+    "github.com/hjjg200/minecraft-forwarder/pkg/rcon"
+)
+
+// RCONConfig
+// Commands run over rcon before the machine of a manager is stopped
+type RCONConfig struct {
+    Address string `json:"address"` // host is taken from the manager if omitted
+    Password string `json:"password"`
+    Commands []string `json:"commands"`
+    Timeout int `json:"timeout"` // unit: seconds, for the port to close
+}
+
+var DefaultRCONCommands = []string{"save-all flush", "stop"}
+
+const (
+    DefaultRCONPort = "25575"
+    gracefulPoll = time.Second
+)
+
+// GracefulManager
+// Wraps a manager so that Stop saves and stops the minecraft server and
+// waits for its port to close before stopping the machine
+type GracefulManager struct {
+    Manager
+    cfg RCONConfig
+}
+
+func NewGracefulManager(m Manager, cfg RCONConfig) *GracefulManager {
+    if len(cfg.Commands) == 0 {
+        cfg.Commands = DefaultRCONCommands
+    }
+    if cfg.Address == "" {
+        cfg.Address = ":" + DefaultRCONPort
+    }
+    if cfg.Timeout == 0 {
+        cfg.Timeout = 60
+    }
+    return &GracefulManager{m, cfg}
+}
+
+func(gm *GracefulManager) rconAddr() (string, error) {
+
+    host, port, err := net.SplitHostPort(gm.cfg.Address)
+    if err != nil {
+        return "", err
+    }
+    if host != "" {
+        return gm.cfg.Address, nil
+    }
+
+    host, _, err = net.SplitHostPort(gm.Addr())
+    if err != nil {
+        return "", err
+    }
+    return net.JoinHostPort(host, port), nil
+
+}
+
+func(gm *GracefulManager) timeout() time.Duration {
+    return time.Duration(gm.cfg.Timeout) * time.Second
+}
+
+// RunCommands runs the configured commands and returns the responses
+func(gm *GracefulManager) RunCommands() ([]string, error) {
+
+    addr, err := gm.rconAddr()
+    if err != nil {
+        return nil, err
+    }
+
+    c, err := rcon.Dial(addr, gm.cfg.Password, gm.timeout())
+    if err != nil {
+        return nil, err
+    }
+    defer c.Close()
+
+    var rsps []string
+    for _, cmd := range gm.cfg.Commands {
+        c.SetDeadline(time.Now().Add(gm.timeout()))
+        rsp, err := c.Command(cmd)
+        if err != nil {
+            // stop may close the connection before answering
+            if cmd == "stop" {
+                break
+            }
+            return rsps, err
+        }
+        rsps = append(rsps, rsp)
+    }
+
+    return rsps, nil
+
+}
+
+// waitClosed waits for the minecraft port to stop accepting connections
+func(gm *GracefulManager) waitClosed() error {
+
+    deadline := time.Now().Add(gm.timeout())
+    for time.Now().Before(deadline) {
+        conn, err := net.DialTimeout("tcp", gm.Addr(), gracefulPoll)
+        if err != nil {
+            return nil
+        }
+        conn.Close()
+        time.Sleep(gracefulPoll)
+    }
+
+    return fmt.Errorf("Minecraft port did not close in time")
+
+}
+
+func(gm *GracefulManager) Stop() error {
+
+    rsps, err := gm.RunCommands()
+    if err != nil {
+        return err
+    }
+    for _, rsp := range rsps {
+        if rsp != "" {
+            fmt.Println("RCON:", rsp)
+        }
+    }
+
+    err = gm.waitClosed()
+    if err != nil {
+        return err
+    }
+
+    return gm.Manager.Stop()
+
+}
+
+func(gm *GracefulManager) Closable() error {
+    return Closable(gm.Manager)
+}
+
+func(gm *GracefulManager) Close() error {
+    return Close(gm.Manager)
+}
+
+func(gm *GracefulManager) SetProxyProtocol(version int) {
+    SetProxyProtocol(gm.Manager, version)
+}
+
+func(gm *GracefulManager) CanStart() bool {
+    return CanStart(gm.Manager)
+}
+
+func(gm *GracefulManager) CanStop() bool {
+    return CanStop(gm.Manager)
+}
+
+func(gm *GracefulManager) Addrs() []string {
+    return Addrs(gm.Manager)
+}
+
+func(gm *GracefulManager) Describe() string {
+    return Describe(gm.Manager)
+}
@@ -0,0 +1,102 @@
+package manager
+
+import (
+    "fmt"
+    "time"
+)
+
+// IdleWatcher
+// Stops the machine of a manager once its minecraft servers have had no
+// players online for the given limit
+type IdleWatcher struct {
+    m Manager
+    limit time.Duration
+    interval time.Duration
+    since time.Time
+    done chan struct{}
+    prober
+}
+
+const (
+    DefaultIdleInterval = time.Minute
+    idleProbeTimeout = 10 * time.Second
+)
+
+func NewIdleWatcher(m Manager, limit time.Duration) *IdleWatcher {
+    return &IdleWatcher{
+        m: m,
+        limit: limit,
+        interval: DefaultIdleInterval,
+        done: make(chan struct{}),
+    }
+}
+
+func(iw *IdleWatcher) SetInterval(interval time.Duration) {
+    iw.interval = interval
+}
+
+// Run polls the manager until Close is called
+func(iw *IdleWatcher) Run() {
+
+    ticker := time.NewTicker(iw.interval)
+    defer ticker.Stop()
+
+    for {
+        select {
+        case <-iw.done:
+            return
+        case <-ticker.C:
+            if err := iw.check(); err != nil {
+                fmt.Println("Idle check failed:", err)
+            }
+        }
+    }
+
+}
+
+func(iw *IdleWatcher) Close() {
+    close(iw.done)
+}
+
+func(iw *IdleWatcher) check() error {
+
+    state, err := iw.m.State()
+    if err != nil {
+        iw.since = time.Time{}
+        return err
+    }
+    if state != StateRunning {
+        iw.since = time.Time{}
+        return nil
+    }
+
+    // Every running server of a pool counts, as Stop stops all of them
+    online := 0
+    for _, addr := range Addrs(iw.m) {
+        rsp, err := iw.status(addr, idleProbeTimeout)
+        if err != nil {
+            iw.since = time.Time{}
+            return err
+        }
+        online += rsp.Players.Online
+    }
+
+    if online > 0 {
+        iw.since = time.Time{}
+        return nil
+    }
+
+    now := time.Now()
+    if iw.since.IsZero() {
+        iw.since = now
+        return nil
+    }
+    if now.Sub(iw.since) < iw.limit {
+        return nil
+    }
+
+    iw.since = time.Time{}
+    fmt.Println("Stopping idle server at", iw.m.Addr())
+    return iw.m.Stop()
+
+}
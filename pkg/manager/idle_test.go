@@ -0,0 +1,124 @@
+package manager
+
+import (
+    "testing"
+    "time"
+
+    "github.com/hjjg200/minecraft-forwarder/pkg/packet"
+)
+
+func TestIdleWatcher(t *testing.T) {
+
+    empty := statusServer(t, packet.Response{})
+    defer empty.Close()
+    busy := statusServer(t, packet.Response{Players: packet.PlayersStruct{Max: 20, Online: 2}})
+    defer busy.Close()
+
+    limit := 100 * time.Millisecond
+    fm := &fakeManager{state: StateRunning, addr: empty.Addr().String()}
+    iw := NewIdleWatcher(fm, limit)
+
+    check := func(expected int) {
+        t.Helper()
+        if err := iw.check(); err != nil {
+            t.Fatal(err)
+        }
+        if fm.state != expected {
+            t.Fatalf("Expected %s, got %s", StateName(expected), StateName(fm.state))
+        }
+    }
+
+    // Counting down from the first empty status
+    check(StateRunning)
+    time.Sleep(limit)
+    check(StateStopping)
+
+    // Players joining start the count over
+    fm.state = StateRunning
+    check(StateRunning)
+    fm.addr = busy.Addr().String()
+    time.Sleep(limit)
+    check(StateRunning)
+    fm.addr = empty.Addr().String()
+    check(StateRunning)
+    time.Sleep(limit)
+    check(StateStopping)
+
+    // So does a server that is not running
+    fm.state = StateRunning
+    check(StateRunning)
+    fm.state = StatePending
+    time.Sleep(limit)
+    check(StatePending)
+    fm.state = StateRunning
+    check(StateRunning)
+
+}
+
+func TestIdleWatcherPool(t *testing.T) {
+
+    empty := statusServer(t, packet.Response{})
+    defer empty.Close()
+    busy := statusServer(t, packet.Response{Players: packet.PlayersStruct{Max: 20, Online: 2}})
+    defer busy.Close()
+
+    limit := 100 * time.Millisecond
+    first := &fakeManager{state: StateRunning, addr: empty.Addr().String()}
+    second := &fakeManager{state: StateRunning, addr: busy.Addr().String()}
+    pm, err := NewPoolManager([]Manager{first, second}, PolicyRoundRobin)
+    if err != nil {
+        t.Fatal(err)
+    }
+    iw := NewIdleWatcher(pm, limit)
+
+    // Players on any member keep the whole pool up
+    for i := 0; i < 2; i++ {
+        if err := iw.check(); err != nil {
+            t.Fatal(err)
+        }
+        time.Sleep(limit)
+    }
+    if first.state != StateRunning || second.state != StateRunning {
+        t.Fatal("Pool with players was stopped")
+    }
+
+    second.addr = empty.Addr().String()
+    for i := 0; i < 2; i++ {
+        if err := iw.check(); err != nil {
+            t.Fatal(err)
+        }
+        time.Sleep(limit)
+    }
+    if first.state != StateStopping || second.state != StateStopping {
+        t.Error("Idle pool was not stopped")
+    }
+
+}
+
+func TestIdleWatcherClose(t *testing.T) {
+
+    empty := statusServer(t, packet.Response{})
+    defer empty.Close()
+
+    fm := &fakeManager{state: StateRunning, addr: empty.Addr().String()}
+    iw := NewIdleWatcher(fm, 50 * time.Millisecond)
+    iw.SetInterval(20 * time.Millisecond)
+
+    exited := make(chan struct{})
+    go func() {
+        iw.Run()
+        close(exited)
+    }()
+
+    time.Sleep(200 * time.Millisecond)
+    iw.Close()
+    select {
+    case <-exited:
+    case <-time.After(time.Second):
+        t.Fatal("Run did not return after Close")
+    }
+    if fm.state != StateStopping {
+        t.Error("Idle server was not stopped")
+    }
+
+}
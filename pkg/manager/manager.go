@@ -16,14 +16,138 @@ const (
     StateStopping // minecraft server not responding, prev state is running
 )
 
+func StateName(state int) string {
+    switch state {
+    case StateStopped: return "stopped"
+    case StatePending: return "pending"
+    case StateRunning: return "running"
+    case StateStopping: return "stopping"
+    }
+    return "obscure"
+}
+
+// ErrNotSupported is returned by managers for what their servers cannot do
+var ErrNotSupported = fmt.Errorf("Not supported")
+
 type Manager interface {
     Start() error
+    Stop() error
     State() (int, error)
     Addr() string
     Dial() (net.Conn, error)
 }
 
-func dialTimeout(addr string, timeout time.Duration) (net.Conn, error) {
+// Describer
+// Implemented by managers whose machine has a state of its own, such as
+// that of an EC2 instance, which tells more than the states above
+type Describer interface {
+    Describe() string // as of the last State, empty if unknown
+}
+
+// Describe returns the machine state of the manager if it is a Describer
+func Describe(m Manager) string {
+    if d, ok := m.(Describer); ok {
+        return d.Describe()
+    }
+    return ""
+}
+
+// Limited
+// Implemented by managers whose servers cannot always be started or
+// stopped from here, such as static ones; the others can be both
+type Limited interface {
+    CanStart() bool
+    CanStop() bool
+}
+
+// CanStart tells whether Start is supported by the manager
+func CanStart(m Manager) bool {
+    if l, ok := m.(Limited); ok {
+        return l.CanStart()
+    }
+    return true
+}
+
+// CanStop tells whether Stop is supported by the manager
+func CanStop(m Manager) bool {
+    if l, ok := m.(Limited); ok {
+        return l.CanStop()
+    }
+    return true
+}
+
+// Multi
+// Implemented by managers of several servers, such as a pool, whose idle
+// checks count the players of all of them rather than of Addr alone
+type Multi interface {
+    Addrs() []string // of the running servers, as of the last State
+}
+
+// Addrs returns the addresses of the running servers of the manager, or its
+// address if it is not a Multi
+func Addrs(m Manager) []string {
+    if multi, ok := m.(Multi); ok {
+        return multi.Addrs()
+    }
+    return []string{m.Addr()}
+}
+
+// Closer
+// Implemented by managers with resources of their own, such as a process
+// or background probes, which Close releases when the manager is replaced.
+// Closable fails while the server would be orphaned by that.
+type Closer interface {
+    Closable() error
+    Close() error
+}
+
+// Closable tells whether the manager can be replaced
+func Closable(m Manager) error {
+    if c, ok := m.(Closer); ok {
+        return c.Closable()
+    }
+    return nil
+}
+
+// Close closes the manager if it is a Closer
+func Close(m Manager) error {
+    if c, ok := m.(Closer); ok {
+        return c.Close()
+    }
+    return nil
+}
+
+// ProxyProber
+// Implemented by managers whose status requests can start with a PROXY
+// header, for backends that only accept proxied connections
+type ProxyProber interface {
+    SetProxyProtocol(version int)
+}
+
+// SetProxyProtocol sets the PROXY header version of the status requests of
+// the manager if it is a ProxyProber
+func SetProxyProtocol(m Manager, version int) {
+    if pp, ok := m.(ProxyProber); ok {
+        pp.SetProxyProtocol(version)
+    }
+}
+
+// prober
+// Makes the status requests of a manager, embedded to make it a ProxyProber
+type prober struct {
+    proxyProtocol int // PROXY header version, 0 sends none
+}
+
+func(pr *prober) SetProxyProtocol(version int) {
+    pr.proxyProtocol = version
+}
+
+func(pr *prober) status(addr string, timeout time.Duration) (packet.Response, error) {
+    return ProbeStatus(addr, pr.proxyProtocol, timeout)
+}
+
+// dial connects to the minecraft server once it answers statuses
+func(pr *prober) dial(addr string, timeout time.Duration) (net.Conn, error) {
 
     c := make(chan error, 1)
 
@@ -40,10 +164,32 @@ func dialTimeout(addr string, timeout time.Duration) (net.Conn, error) {
             c <-err
         }
 
-        _, err = packet.Status(addr)
+        _, err = packet.StatusProxy(addr, pr.proxyProtocol)
         c <- err
     }()
 
     return dst, <-c
 
 }
+
+// ProbeStatus is packet.StatusProxy giving up after the timeout
+func ProbeStatus(addr string, version int, timeout time.Duration) (packet.Response, error) {
+
+    type result struct {
+        rsp packet.Response
+        err error
+    }
+    c := make(chan result, 1)
+    go func() {
+        rsp, err := packet.StatusProxy(addr, version)
+        c <- result{rsp, err}
+    }()
+
+    select {
+    case r := <-c:
+        return r.rsp, r.err
+    case <-time.After(timeout):
+        return packet.Response{}, fmt.Errorf("Status of %s timed out", addr)
+    }
+
+}
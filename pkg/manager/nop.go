@@ -14,10 +14,22 @@ func NewNopManager() *NopManager {
     return &NopManager{}
 }
 
+func(nop *NopManager) CanStart() bool {
+    return false
+}
+
+func(nop *NopManager) CanStop() bool {
+    return false
+}
+
 func(nop *NopManager) Start() error {
     return ErrNop
 }
 
+func(nop *NopManager) Stop() error {
+    return ErrNop
+}
+
 func(nop *NopManager) State() (int, error) {
     return StateObscure, ErrNop
 }
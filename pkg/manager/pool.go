@@ -0,0 +1,353 @@
+package manager
+
+import (
+    "fmt"
+    "net"
+    "sort"
+    "strings"
+    "sync"
+    "sync/atomic"
+    "time"
+
+    "github.com/hjjg200/minecraft-forwarder/pkg/packet"
+)
+
+// Pool
+// Backends of one server, e.g. identical lobbies, each with its own
+// manager. Dial picks among the running ones by the policy and fails over
+// to the next when dialing fails. The pool is running while any of them
+// is, and Start and Stop apply to all of them.
+type PoolManager struct {
+    next uint64 // for round-robin, first for atomic alignment
+    members []*poolMember
+    policy string
+    prober
+    lock sync.Mutex
+}
+
+type poolMember struct {
+    sessions int64 // live connections dialed through the pool
+    Manager
+    state int // as of the last State
+    status packet.Response
+    ping time.Duration // of the last status, 0 if it failed
+    probed time.Time
+}
+
+const (
+    PolicyRoundRobin = "round-robin"
+    PolicyLeastConnections = "least-connections"
+    PolicyLowestPing = "lowest-ping"
+    PolicyFillFirst = "fill-first"
+    poolProbeAge = 10 * time.Second // of the statuses lowest-ping and fill-first go by
+    poolProbeTimeout = 5 * time.Second
+)
+
+func NewPoolManager(members []Manager, policy string) (*PoolManager, error) {
+
+    switch policy {
+    case "":
+        policy = PolicyRoundRobin
+    case PolicyRoundRobin, PolicyLeastConnections, PolicyLowestPing, PolicyFillFirst:
+    default:
+        return nil, fmt.Errorf("Unknown pool policy %s", policy)
+    }
+    if len(members) == 0 {
+        return nil, fmt.Errorf("Pool without a backend")
+    }
+
+    pm := &PoolManager{policy: policy}
+    for _, m := range members {
+        pm.members = append(pm.members, &poolMember{Manager: m, state: StateObscure})
+    }
+
+    return pm, nil
+
+}
+
+// Start starts the members that are stopped, failing only if none of them
+// could be
+func(pm *PoolManager) Start() error {
+
+    var err error
+    started := false
+    for _, member := range pm.members {
+        state, _ := member.State()
+        if state != StateStopped {
+            continue
+        }
+        if e := member.Start(); e != nil {
+            err = e
+            continue
+        }
+        started = true
+    }
+
+    if started {
+        return nil
+    } else if err != nil {
+        return err
+    }
+    return fmt.Errorf("No backend of the pool is stopped")
+
+}
+
+func(pm *PoolManager) Stop() error {
+    var err error
+    for _, member := range pm.members {
+        if e := member.Stop(); e != nil {
+            err = e
+        }
+    }
+    return err
+}
+
+// CanStart is true if any member can be started
+func(pm *PoolManager) CanStart() bool {
+    for _, member := range pm.members {
+        if CanStart(member.Manager) {
+            return true
+        }
+    }
+    return false
+}
+
+// CanStop is true if any member can be stopped
+func(pm *PoolManager) CanStop() bool {
+    for _, member := range pm.members {
+        if CanStop(member.Manager) {
+            return true
+        }
+    }
+    return false
+}
+
+// SetProxyProtocol applies to the probes of the pool and of its members
+func(pm *PoolManager) SetProxyProtocol(version int) {
+    pm.prober.SetProxyProtocol(version)
+    for _, member := range pm.members {
+        SetProxyProtocol(member.Manager, version)
+    }
+}
+
+// Describe lists the machine states of the members that have one
+func(pm *PoolManager) Describe() string {
+    var descs []string
+    for _, member := range pm.members {
+        if desc := Describe(member.Manager); desc != "" {
+            descs = append(descs, desc)
+        }
+    }
+    return strings.Join(descs, ", ")
+}
+
+// Closable fails if any member is not
+func(pm *PoolManager) Closable() error {
+    for _, member := range pm.members {
+        if err := Closable(member.Manager); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// Close closes every member
+func(pm *PoolManager) Close() error {
+    var err error
+    for _, member := range pm.members {
+        if e := Close(member.Manager); e != nil {
+            err = e
+        }
+    }
+    return err
+}
+
+// State is the most available of the members: running, then pending,
+// stopping and stopped. It fails only if all of them fail.
+func(pm *PoolManager) State() (int, error) {
+
+    states := make([]int, len(pm.members))
+    errs := make([]error, len(pm.members))
+    var wg sync.WaitGroup
+    for i, member := range pm.members {
+        wg.Add(1)
+        go func(i int, member *poolMember) {
+            defer wg.Done()
+            states[i], errs[i] = member.State()
+        }(i, member)
+    }
+    wg.Wait()
+
+    pm.lock.Lock()
+    defer pm.lock.Unlock()
+
+    rank := map[int] int{
+        StateRunning: 4, StatePending: 3, StateStopping: 2, StateStopped: 1,
+    }
+    best, failed := StateObscure, 0
+    for i, member := range pm.members {
+        member.state = states[i]
+        if errs[i] != nil {
+            failed++
+            continue
+        }
+        if rank[states[i]] > rank[best] {
+            best = states[i]
+        }
+    }
+    if failed == len(pm.members) {
+        return StateObscure, errs[0]
+    }
+
+    return best, nil
+
+}
+
+// Addr is the address of the first running member, or of the first one
+func(pm *PoolManager) Addr() string {
+    pm.lock.Lock()
+    defer pm.lock.Unlock()
+    for _, member := range pm.members {
+        if member.state == StateRunning {
+            return member.Addr()
+        }
+    }
+    return pm.members[0].Addr()
+}
+
+// Addrs are the addresses of the running members
+func(pm *PoolManager) Addrs() []string {
+    pm.lock.Lock()
+    defer pm.lock.Unlock()
+    var addrs []string
+    for _, member := range pm.members {
+        if member.state == StateRunning {
+            addrs = append(addrs, member.Addr())
+        }
+    }
+    return addrs
+}
+
+// Dial tries the running members in the order of the policy
+func(pm *PoolManager) Dial() (net.Conn, error) {
+
+    order := pm.order()
+    if len(order) == 0 {
+        return nil, fmt.Errorf("No backend of the pool is running")
+    }
+
+    var err error
+    for _, member := range order {
+        var conn net.Conn
+        conn, err = member.Dial()
+        if err == nil {
+            atomic.AddInt64(&member.sessions, 1)
+            return &poolConn{Conn: conn, member: member}, nil
+        }
+        fmt.Println("Pool backend", member.Addr(), "failed, trying the next:", err)
+    }
+
+    return nil, err
+
+}
+
+// order sorts the running members by the policy
+func(pm *PoolManager) order() []*poolMember {
+
+    pm.lock.Lock()
+    var running []*poolMember
+    for _, member := range pm.members {
+        if member.state == StateRunning {
+            running = append(running, member)
+        }
+    }
+    pm.lock.Unlock()
+
+    if len(running) == 0 {
+        return nil
+    }
+
+    switch pm.policy {
+    case PolicyRoundRobin:
+        n := int(atomic.AddUint64(&pm.next, 1) - 1) % len(running)
+        return append(running[n:], running[:n]...)
+    case PolicyLeastConnections:
+        sort.SliceStable(running, func(i, j int) bool {
+            return atomic.LoadInt64(&running[i].sessions) < atomic.LoadInt64(&running[j].sessions)
+        })
+    case PolicyLowestPing:
+        pm.probe(running)
+        pm.lock.Lock()
+        sort.SliceStable(running, func(i, j int) bool {
+            a, b := running[i].ping, running[j].ping
+            // Failed probes go last
+            return a != 0 && (b == 0 || a < b)
+        })
+        pm.lock.Unlock()
+    case PolicyFillFirst:
+        pm.probe(running)
+        pm.lock.Lock()
+        sort.SliceStable(running, func(i, j int) bool {
+            return !running[i].full() && running[j].full()
+        })
+        pm.lock.Unlock()
+    }
+
+    return running
+
+}
+
+// probe refreshes the statuses that are older than poolProbeAge
+func(pm *PoolManager) probe(members []*poolMember) {
+
+    var wg sync.WaitGroup
+    for _, member := range members {
+        pm.lock.Lock()
+        fresh := time.Since(member.probed) < poolProbeAge
+        pm.lock.Unlock()
+        if fresh {
+            continue
+        }
+
+        wg.Add(1)
+        go func(member *poolMember) {
+            defer wg.Done()
+            start := time.Now()
+            rsp, err := pm.status(member.Addr(), poolProbeTimeout)
+            ping := time.Since(start)
+
+            pm.lock.Lock()
+            defer pm.lock.Unlock()
+            member.probed = time.Now()
+            member.status = rsp
+            member.ping = ping
+            if err != nil {
+                member.ping = 0
+            }
+        }(member)
+    }
+    wg.Wait()
+
+}
+
+// full reports whether the member has no room by its last status, which
+// is not known to be the case when the status failed
+func(member *poolMember) full() bool {
+    players := member.status.Players
+    return member.ping != 0 && players.Max > 0 && players.Online >= players.Max
+}
+
+// poolConn
+// Counts the sessions of a member until closed
+type poolConn struct {
+    net.Conn
+    member *poolMember
+    once sync.Once
+}
+
+func(pc *poolConn) Close() error {
+    pc.once.Do(func() {
+        atomic.AddInt64(&pc.member.sessions, -1)
+    })
+    return pc.Conn.Close()
+}
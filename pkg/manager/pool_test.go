@@ -0,0 +1,193 @@
+package manager
+
+import (
+    "fmt"
+    "net"
+    "testing"
+
+    "github.com/hjjg200/minecraft-forwarder/pkg/packet"
+)
+
+// fakeManager
+// Reports a fixed state and dials by returning a pipe, unless it is broken
+type fakeManager struct {
+    state int
+    addr string
+    broken bool
+    dials int
+}
+
+func(fm *fakeManager) Start() error {
+    fm.state = StatePending
+    return nil
+}
+
+func(fm *fakeManager) Stop() error {
+    fm.state = StateStopping
+    return nil
+}
+
+func(fm *fakeManager) State() (int, error) {
+    if fm.state == StateObscure {
+        return StateObscure, fmt.Errorf("Obscure")
+    }
+    return fm.state, nil
+}
+
+func(fm *fakeManager) Addr() string {
+    return fm.addr
+}
+
+func(fm *fakeManager) Dial() (net.Conn, error) {
+    fm.dials++
+    if fm.broken {
+        return nil, fmt.Errorf("Broken")
+    }
+    a, b := net.Pipe()
+    b.Close()
+    return a, nil
+}
+
+func newTestPool(t *testing.T, policy string, fms ...*fakeManager) *PoolManager {
+    members := make([]Manager, len(fms))
+    for i, fm := range fms {
+        members[i] = fm
+    }
+    pm, err := NewPoolManager(members, policy)
+    if err != nil {
+        t.Fatal(err)
+    }
+    pm.State()
+    return pm
+}
+
+// dialed tells which of the members the connection is of
+func dialed(t *testing.T, pm *PoolManager) (Manager, net.Conn) {
+    conn, err := pm.Dial()
+    if err != nil {
+        t.Fatal(err)
+    }
+    return conn.(*poolConn).member.Manager, conn
+}
+
+func TestPoolState(t *testing.T) {
+
+    samples := []struct {
+        states []int
+        expected int
+        fails bool
+    }{
+        {[]int{StateStopped, StateRunning, StatePending}, StateRunning, false},
+        {[]int{StateStopped, StateStopping, StatePending}, StatePending, false},
+        {[]int{StateStopped, StateStopping}, StateStopping, false},
+        {[]int{StateObscure, StateStopped}, StateStopped, false},
+        {[]int{StateObscure, StateObscure}, StateObscure, true},
+    }
+
+    for _, sample := range samples {
+        fms := make([]*fakeManager, len(sample.states))
+        for i, state := range sample.states {
+            fms[i] = &fakeManager{state: state}
+        }
+        pm := newTestPool(t, "", fms...)
+        state, err := pm.State()
+        if state != sample.expected || (err != nil) != sample.fails {
+            t.Errorf("%v: expected %d, got %d %v", sample.states, sample.expected, state, err)
+        }
+    }
+
+    // Only the stopped ones are started
+    a, b := &fakeManager{state: StateStopped}, &fakeManager{state: StateRunning}
+    pm := newTestPool(t, "", a, b)
+    if err := pm.Start(); err != nil || a.state != StatePending || b.state != StateRunning {
+        t.Error("Wrong start", err, a.state, b.state)
+    }
+
+}
+
+func TestPoolRoundRobin(t *testing.T) {
+
+    a, b, c := &fakeManager{state: StateRunning}, &fakeManager{state: StateStopped}, &fakeManager{state: StateRunning}
+    pm := newTestPool(t, PolicyRoundRobin, a, b, c)
+
+    for _, expected := range []*fakeManager{a, c, a, c} {
+        m, conn := dialed(t, pm)
+        conn.Close()
+        if m != expected {
+            t.Error("Wrong member")
+        }
+    }
+    if b.dials != 0 {
+        t.Error("Dialed a stopped member")
+    }
+
+    // Failover
+    a.broken = true
+    for i := 0; i < 2; i++ {
+        m, conn := dialed(t, pm)
+        conn.Close()
+        if m != c {
+            t.Error("Did not fail over")
+        }
+    }
+
+    c.broken = true
+    if _, err := pm.Dial(); err == nil {
+        t.Error("Dialed broken members")
+    }
+
+}
+
+func TestPoolLeastConnections(t *testing.T) {
+
+    a, b := &fakeManager{state: StateRunning}, &fakeManager{state: StateRunning}
+    pm := newTestPool(t, PolicyLeastConnections, a, b)
+
+    m1, conn1 := dialed(t, pm)
+    m2, conn2 := dialed(t, pm)
+    if m1 != a || m2 != b {
+        t.Error("Connections were not spread")
+    }
+    m3, conn3 := dialed(t, pm)
+    conn3.Close()
+    conn3.Close()
+    if m3 != a {
+        t.Error("Ties are not in order")
+    }
+
+    conn1.Close()
+    m4, conn4 := dialed(t, pm)
+    defer conn4.Close()
+    defer conn2.Close()
+    if m4 != a {
+        t.Error("Closed connections are still counted")
+    }
+
+}
+
+func TestPoolStatusPolicies(t *testing.T) {
+
+    full := statusServer(t, packet.Response{Players: packet.PlayersStruct{Max: 2, Online: 2}})
+    defer full.Close()
+    room := statusServer(t, packet.Response{Players: packet.PlayersStruct{Max: 2, Online: 1}})
+    defer room.Close()
+    dead := closedAddr(t)
+
+    // Fill-first skips the full ones but keeps the order otherwise
+    a := &fakeManager{state: StateRunning, addr: full.Addr().String()}
+    b := &fakeManager{state: StateRunning, addr: room.Addr().String()}
+    c := &fakeManager{state: StateRunning, addr: dead}
+    pm := newTestPool(t, PolicyFillFirst, a, b, c)
+    order := pm.order()
+    if order[0].Manager != b || order[1].Manager != c || order[2].Manager != a {
+        t.Error("Wrong fill-first order")
+    }
+
+    // Lowest ping puts failed probes last
+    pm = newTestPool(t, PolicyLowestPing, c, b)
+    order = pm.order()
+    if order[0].Manager != b || order[1].Manager != c {
+        t.Error("Wrong lowest-ping order")
+    }
+
+}
@@ -0,0 +1,294 @@
+package manager
+
+import (
+    "encoding/json"
+    "fmt"
+    "io"
+    "net"
+    "os"
+    "os/exec"
+    "sync"
+    "time"
+)
+
+// Process
+// Launches the minecraft server itself, e.g. java -jar server.jar nogui
+type ProcessManager struct {
+    Command []string `json:"command"`
+    Dir string `json:"dir"`
+    Env []string `json:"env"` // KEY=VALUE, added to the environment of the forwarder
+    Stdin string `json:"stdin"` // written to the process once it is launched
+    StopCommand string `json:"stopCommand"`
+    StopTimeout int `json:"stopTimeout"` // unit: seconds, the process is killed afterwards
+    Address string `json:"address"` // host:port of the minecraft server
+    Timeout int `json:"timeout"` // unit: seconds
+    LogPath string `json:"logPath"` // stdout and stderr, discarded if empty
+    LogSize int `json:"logSize"` // unit: megabytes
+    LogBackups int `json:"logBackups"`
+    Restart bool `json:"restart"` // restart on crash
+    cmd *exec.Cmd
+    stdin io.WriteCloser
+    exited chan struct{}
+    log io.Writer
+    wanted bool // Start was called and Stop was not
+    stopping bool
+    restarting bool
+    prober
+    lock sync.Mutex
+}
+
+const (
+    processMinBackoff = time.Second
+    processMaxBackoff = 5 * time.Minute
+    processStable = 10 * time.Minute // resets the backoff
+    processWaitDelay = 5 * time.Second // for children holding the output open
+)
+
+func newProcessManager() *ProcessManager {
+    return &ProcessManager{
+        StopCommand: "stop",
+        StopTimeout: 60,
+        Timeout: 10,
+        LogSize: 10,
+        LogBackups: 5,
+        Restart: true,
+    }
+}
+
+func NewProcessManager(cmd []string, dir, addr string) *ProcessManager {
+    pm := newProcessManager()
+    pm.Command = cmd
+    pm.Dir = dir
+    pm.Address = addr
+    return pm
+}
+
+func NewProcessManagerJson(data []byte) (*ProcessManager, error) {
+    pm := newProcessManager()
+    return pm, json.Unmarshal(data, pm)
+}
+
+func(pm *ProcessManager) Addr() string {
+    return pm.Address
+}
+
+// launch expects the lock to be held
+func(pm *ProcessManager) launch() error {
+
+    if len(pm.Command) == 0 {
+        return fmt.Errorf("No command to launch")
+    }
+
+    if pm.log == nil {
+        pm.log = io.Discard
+        if pm.LogPath != "" {
+            rf, err := newRotatingFile(pm.LogPath, int64(pm.LogSize) << 20, pm.LogBackups)
+            if err != nil {
+                return err
+            }
+            pm.log = rf
+        }
+    }
+
+    cmd := exec.Command(pm.Command[0], pm.Command[1:]...)
+    cmd.Dir = pm.Dir
+    cmd.Env = append(os.Environ(), pm.Env...)
+    cmd.Stdout = pm.log
+    cmd.Stderr = pm.log
+    cmd.WaitDelay = processWaitDelay
+
+    stdin, err := cmd.StdinPipe()
+    if err != nil {
+        return err
+    }
+
+    err = cmd.Start()
+    if err != nil {
+        return err
+    }
+
+    if pm.Stdin != "" {
+        io.WriteString(stdin, pm.Stdin)
+    }
+
+    pm.cmd = cmd
+    pm.stdin = stdin
+    pm.exited = make(chan struct{})
+    return nil
+
+}
+
+// supervise waits for the process and restarts it when it crashes
+func(pm *ProcessManager) supervise(cmd *exec.Cmd) {
+
+    backoff := processMinBackoff
+
+    for {
+        start := time.Now()
+        err := cmd.Wait()
+
+        pm.lock.Lock()
+        pm.cmd = nil
+        pm.stdin = nil
+        pm.stopping = false
+        close(pm.exited)
+        if !pm.wanted || !pm.Restart {
+            pm.wanted = false
+            pm.lock.Unlock()
+            return
+        }
+        if time.Since(start) >= processStable {
+            backoff = processMinBackoff
+        }
+        pm.restarting = true
+        pm.lock.Unlock()
+
+        fmt.Println("Process exited unexpectedly:", err, "restarting in", backoff)
+        time.Sleep(backoff)
+        backoff *= 2
+        if backoff > processMaxBackoff {
+            backoff = processMaxBackoff
+        }
+
+        pm.lock.Lock()
+        pm.restarting = false
+        if !pm.wanted {
+            pm.lock.Unlock()
+            return
+        }
+        err = pm.launch()
+        if err != nil {
+            pm.wanted = false
+            pm.lock.Unlock()
+            fmt.Println("Process restart failed:", err)
+            return
+        }
+        cmd = pm.cmd
+        pm.lock.Unlock()
+    }
+
+}
+
+func(pm *ProcessManager) Start() error {
+
+    pm.lock.Lock()
+    defer pm.lock.Unlock()
+
+    if pm.cmd != nil || pm.restarting {
+        pm.wanted = true
+        return nil
+    }
+
+    err := pm.launch()
+    if err != nil {
+        return err
+    }
+
+    pm.wanted = true
+    go pm.supervise(pm.cmd)
+
+    return nil
+
+}
+
+// Stop asks the server to stop and waits for it, killing it after the
+// stop timeout
+func(pm *ProcessManager) Stop() error {
+
+    pm.lock.Lock()
+    pm.wanted = false
+    cmd, stdin, exited := pm.cmd, pm.stdin, pm.exited
+    if cmd != nil {
+        pm.stopping = true
+    }
+    pm.lock.Unlock()
+
+    if cmd == nil {
+        return nil
+    }
+
+    _, err := io.WriteString(stdin, pm.StopCommand + "\n")
+    if err != nil {
+        cmd.Process.Kill()
+    }
+
+    select {
+    case <-exited:
+        return nil
+    case <-time.After(time.Duration(pm.StopTimeout) * time.Second):
+    }
+
+    fmt.Println("Process did not stop in time, killing it")
+    err = cmd.Process.Kill()
+    <-exited
+
+    return err
+
+}
+
+func(pm *ProcessManager) State() (int, error) {
+
+    pm.lock.Lock()
+    cmd, stopping, restarting := pm.cmd, pm.stopping, pm.restarting
+    pm.lock.Unlock()
+
+    switch {
+    case restarting:
+        return StatePending, nil
+    case cmd == nil:
+        return StateStopped, nil
+    case stopping:
+        return StateStopping, nil
+    }
+
+    // JVM is up, check whether the world is loaded
+    conn, err := pm.Dial()
+    if err != nil {
+        return StatePending, nil
+    }
+    conn.Close()
+
+    return StateRunning, nil
+
+}
+
+// Closable fails while the process runs, as it is not handed over to
+// another manager
+func(pm *ProcessManager) Closable() error {
+    pm.lock.Lock()
+    defer pm.lock.Unlock()
+    return pm.closable()
+}
+
+func(pm *ProcessManager) closable() error {
+    if pm.cmd != nil || pm.restarting || pm.wanted {
+        return fmt.Errorf("Process is still running, stop it first")
+    }
+    return nil
+}
+
+// Close releases the log file once the process is stopped
+func(pm *ProcessManager) Close() error {
+
+    pm.lock.Lock()
+    defer pm.lock.Unlock()
+
+    if err := pm.closable(); err != nil {
+        return err
+    }
+
+    if c, ok := pm.log.(io.Closer); ok {
+        pm.log = nil
+        return c.Close()
+    }
+    return nil
+
+}
+
+func(pm *ProcessManager) timeout() time.Duration {
+    return time.Duration(pm.Timeout) * time.Second
+}
+
+func(pm *ProcessManager) Dial() (net.Conn, error) {
+    return pm.dial(pm.Address, pm.timeout())
+}
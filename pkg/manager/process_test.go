@@ -0,0 +1,120 @@
+package manager
+
+import (
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+    "time"
+)
+
+func waitState(t *testing.T, m Manager, expected int) {
+    for i := 0; i < 50; i++ {
+        state, _ := m.State()
+        if state == expected {
+            return
+        }
+        time.Sleep(100 * time.Millisecond)
+    }
+    t.Fatalf("State did not become %d", expected)
+}
+
+func TestProcessGracefulStop(t *testing.T) {
+
+    log := filepath.Join(t.TempDir(), "server.log")
+    pm := NewProcessManager([]string{
+        "sh", "-c", `echo started; while read line; do echo "> $line"; [ "$line" = stop ] && exit 0; done`,
+    }, "", "localhost:1")
+    pm.Timeout = 1
+    pm.Stdin = "hello\n"
+    pm.LogPath = log
+
+    if err := pm.Start(); err != nil {
+        t.Fatal(err)
+    }
+    waitState(t, pm, StatePending) // nothing listening
+
+    if err := pm.Stop(); err != nil {
+        t.Fatal(err)
+    }
+    waitState(t, pm, StateStopped)
+
+    p, _ := os.ReadFile(log)
+    t.Logf("%q", p)
+    if string(p) != "started\n> hello\n> stop\n" {
+        t.Fail()
+    }
+
+}
+
+func TestProcessKill(t *testing.T) {
+
+    pm := NewProcessManager([]string{"sleep", "30"}, "", "localhost:1")
+    pm.StopTimeout = 1
+
+    if err := pm.Start(); err != nil {
+        t.Fatal(err)
+    }
+    if err := pm.Closable(); err == nil {
+        t.Error("Running process is closable")
+    }
+
+    start := time.Now()
+    pm.Stop()
+    waitState(t, pm, StateStopped)
+    if time.Since(start) > 5 * time.Second {
+        t.Error("Process was not killed")
+    }
+    if err := pm.Closable(); err != nil {
+        t.Error("Stopped process is not closable:", err)
+    }
+    if err := pm.Close(); err != nil {
+        t.Error(err)
+    }
+
+}
+
+func TestProcessRestart(t *testing.T) {
+
+    dir := t.TempDir()
+    pm := NewProcessManager([]string{"sh", "-c", "echo run >> runs; exit 1"}, dir, "localhost:1")
+
+    if err := pm.Start(); err != nil {
+        t.Fatal(err)
+    }
+    time.Sleep(2 * processMinBackoff)
+
+    p, _ := os.ReadFile(filepath.Join(dir, "runs"))
+    runs := strings.Count(string(p), "run")
+    t.Log("runs:", runs)
+    if runs < 2 {
+        t.Fail()
+    }
+
+    pm.Stop()
+    time.Sleep(4 * processMinBackoff)
+    waitState(t, pm, StateStopped)
+
+}
+
+func TestRotatingFile(t *testing.T) {
+
+    path := filepath.Join(t.TempDir(), "log")
+    rf, err := newRotatingFile(path, 4, 2)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer rf.Close()
+
+    for _, s := range []string{"aaa", "bbb", "ccc", "ddd"} {
+        rf.Write([]byte(s))
+    }
+
+    for suffix, expected := range map[string] string{"": "ddd", ".1": "ccc", ".2": "bbb"} {
+        p, _ := os.ReadFile(path + suffix)
+        if string(p) != expected {
+            t.Errorf("%s%s) %q != %q", path, suffix, p, expected)
+        }
+    }
+
+}
@@ -0,0 +1,98 @@
+package manager
+
+import (
+    "fmt"
+    "os"
+    "sync"
+)
+
+// rotatingFile
+// Log file that is renamed to path.1, path.2, ... once it grows past size
+type rotatingFile struct {
+    path string
+    size int64
+    backups int
+    file *os.File
+    written int64
+    lock sync.Mutex
+}
+
+func newRotatingFile(path string, size int64, backups int) (*rotatingFile, error) {
+
+    rf := &rotatingFile{
+        path: path,
+        size: size,
+        backups: backups,
+    }
+
+    return rf, rf.open()
+
+}
+
+func(rf *rotatingFile) open() error {
+
+    file, err := os.OpenFile(rf.path, os.O_WRONLY | os.O_CREATE | os.O_APPEND, 0644)
+    if err != nil {
+        return err
+    }
+
+    info, err := file.Stat()
+    if err != nil {
+        file.Close()
+        return err
+    }
+
+    rf.file = file
+    rf.written = info.Size()
+    return nil
+
+}
+
+func(rf *rotatingFile) rotate() error {
+
+    err := rf.file.Close()
+    if err != nil {
+        return err
+    }
+
+    for i := rf.backups; i > 0; i-- {
+        from := rf.path
+        if i > 1 {
+            from = fmt.Sprintf("%s.%d", rf.path, i - 1)
+        }
+        err = os.Rename(from, fmt.Sprintf("%s.%d", rf.path, i))
+        if err != nil && !os.IsNotExist(err) {
+            return err
+        }
+    }
+    if rf.backups == 0 {
+        os.Remove(rf.path)
+    }
+
+    return rf.open()
+
+}
+
+func(rf *rotatingFile) Write(p []byte) (int, error) {
+
+    rf.lock.Lock()
+    defer rf.lock.Unlock()
+
+    if rf.size > 0 && rf.written > 0 && rf.written + int64(len(p)) > rf.size {
+        err := rf.rotate()
+        if err != nil {
+            return 0, err
+        }
+    }
+
+    n, err := rf.file.Write(p)
+    rf.written += int64(n)
+    return n, err
+
+}
+
+func(rf *rotatingFile) Close() error {
+    rf.lock.Lock()
+    defer rf.lock.Unlock()
+    return rf.file.Close()
+}
@@ -0,0 +1,253 @@
+package manager
+
+import (
+    "encoding/json"
+    "fmt"
+    "net"
+    "sync"
+    "time"
+)
+
+// Static
+// Forwards to always-on servers that cannot be started or stopped. Their
+// state comes from status probes made every interval from the first State
+// until Close; a backend is down after FailThreshold failed probes in a
+// row and up again after RiseThreshold good ones.
+type StaticManager struct {
+    Backends []string `json:"backends"` // host:port
+    Interval int `json:"interval"` // unit: seconds
+    Timeout int `json:"timeout"` // unit: seconds, of a probe or a dial
+    FailThreshold int `json:"failThreshold"`
+    RiseThreshold int `json:"riseThreshold"`
+    backends []*staticBackend
+    probed time.Time
+    first chan struct{} // closed after the first probe
+    done chan struct{}
+    runOnce sync.Once
+    closeOnce sync.Once
+    prober
+    lock sync.Mutex
+}
+
+type staticBackend struct {
+    addr string
+    up bool
+    fails int // in a row
+    rises int
+}
+
+func newStaticManager() *StaticManager {
+    return &StaticManager{
+        Interval: 10,
+        Timeout: 5,
+        FailThreshold: 3,
+        RiseThreshold: 1,
+    }
+}
+
+func NewStaticManager(backends []string) (*StaticManager, error) {
+    sm := newStaticManager()
+    sm.Backends = backends
+    return sm, sm.init()
+}
+
+func NewStaticManagerJson(data []byte) (*StaticManager, error) {
+    sm := newStaticManager()
+    err := json.Unmarshal(data, sm)
+    if err != nil {
+        return nil, err
+    }
+    return sm, sm.init()
+}
+
+func(sm *StaticManager) init() error {
+
+    if len(sm.Backends) == 0 {
+        return fmt.Errorf("No static backend")
+    }
+    if sm.Interval <= 0 || sm.Timeout <= 0 || sm.FailThreshold <= 0 || sm.RiseThreshold <= 0 {
+        return fmt.Errorf("Static interval, timeout and thresholds must be positive")
+    }
+
+    sm.backends = make([]*staticBackend, len(sm.Backends))
+    for i, addr := range sm.Backends {
+        if _, _, err := net.SplitHostPort(addr); err != nil {
+            return fmt.Errorf("Static backend %s: %v", addr, err)
+        }
+        sm.backends[i] = &staticBackend{addr: addr}
+    }
+
+    sm.first = make(chan struct{})
+    sm.done = make(chan struct{})
+
+    return nil
+
+}
+
+// run probes the backends every interval until Close is called
+func(sm *StaticManager) run() {
+
+    sm.probe()
+    close(sm.first)
+
+    ticker := time.NewTicker(sm.interval())
+    defer ticker.Stop()
+
+    for {
+        select {
+        case <-sm.done:
+            return
+        case <-ticker.C:
+            sm.probe()
+        }
+    }
+
+}
+
+// Closable is always true, as the servers are not the manager's
+func(sm *StaticManager) Closable() error {
+    return nil
+}
+
+// Close stops the probes
+func(sm *StaticManager) Close() error {
+    sm.closeOnce.Do(func() {
+        close(sm.done)
+    })
+    return nil
+}
+
+func(sm *StaticManager) CanStart() bool {
+    return false
+}
+
+func(sm *StaticManager) CanStop() bool {
+    return false
+}
+
+func(sm *StaticManager) Start() error {
+    return fmt.Errorf("%w: static servers cannot be started", ErrNotSupported)
+}
+
+func(sm *StaticManager) Stop() error {
+    return fmt.Errorf("%w: static servers cannot be stopped", ErrNotSupported)
+}
+
+// State is running while any backend is up, by the last probes. Calls
+// before the first probes are done wait for them.
+func(sm *StaticManager) State() (int, error) {
+
+    sm.runOnce.Do(func() {
+        go sm.run()
+    })
+    <-sm.first
+
+    sm.lock.Lock()
+    defer sm.lock.Unlock()
+
+    for _, b := range sm.backends {
+        if b.up {
+            return StateRunning, nil
+        }
+    }
+
+    return StateStopped, nil
+
+}
+
+// probe checks every backend at once
+func(sm *StaticManager) probe() {
+
+    results := make([]error, len(sm.backends))
+    var wg sync.WaitGroup
+    for i, b := range sm.backends {
+        wg.Add(1)
+        go func(i int, addr string) {
+            defer wg.Done()
+            _, results[i] = sm.status(addr, sm.timeout())
+        }(i, b.addr)
+    }
+    wg.Wait()
+
+    sm.lock.Lock()
+    defer sm.lock.Unlock()
+
+    first := sm.probed.IsZero()
+    for i, b := range sm.backends {
+        b.observe(results[i] == nil, first, sm.FailThreshold, sm.RiseThreshold)
+    }
+    sm.probed = time.Now()
+
+}
+
+// observe counts a probe, the first one deciding the state on its own
+func(b *staticBackend) observe(ok, first bool, fall, rise int) {
+
+    if ok {
+        b.fails = 0
+        b.rises++
+    } else {
+        b.rises = 0
+        b.fails++
+    }
+
+    switch {
+    case first:
+        b.up = ok
+    case b.up && b.fails >= fall:
+        b.up = false
+    case !b.up && b.rises >= rise:
+        b.up = true
+    }
+
+}
+
+// up returns the addresses of the backends that are up, in order
+func(sm *StaticManager) up() []string {
+    sm.lock.Lock()
+    defer sm.lock.Unlock()
+    addrs := make([]string, 0, len(sm.backends))
+    for _, b := range sm.backends {
+        if b.up {
+            addrs = append(addrs, b.addr)
+        }
+    }
+    return addrs
+}
+
+// Addr is the first backend that is up, or the first one
+func(sm *StaticManager) Addr() string {
+    if addrs := sm.up(); len(addrs) > 0 {
+        return addrs[0]
+    }
+    return sm.Backends[0]
+}
+
+// Dial tries the backends that are up in order
+func(sm *StaticManager) Dial() (net.Conn, error) {
+
+    addrs := sm.up()
+    if len(addrs) == 0 {
+        return nil, fmt.Errorf("No static backend is up")
+    }
+
+    var err error
+    for _, addr := range addrs {
+        var conn net.Conn
+        conn, err = net.DialTimeout("tcp", addr, sm.timeout())
+        if err == nil {
+            return conn, nil
+        }
+    }
+
+    return nil, err
+
+}
+
+func(sm *StaticManager) interval() time.Duration {
+    return time.Duration(sm.Interval) * time.Second
+}
+
+func(sm *StaticManager) timeout() time.Duration {
+    return time.Duration(sm.Timeout) * time.Second
+}
@@ -0,0 +1,221 @@
+package manager
+
+import (
+    "errors"
+    "io"
+    "net"
+    "testing"
+    "time"
+
+    "github.com/hjjg200/minecraft-forwarder/pkg/packet"
+)
+
+// statusServer answers status requests until the listener is closed
+func statusServer(t *testing.T, rsp packet.Response) net.Listener {
+
+    ln, err := net.Listen("tcp", "127.0.0.1:0")
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    go func() {
+        for {
+            conn, err := ln.Accept()
+            if err != nil {
+                return
+            }
+            go func() {
+                defer conn.Close()
+                hs, err := packet.ReadHandshake(conn)
+                if err != nil {
+                    return
+                }
+                packet.ServeResponse(conn, hs, rsp)
+            }()
+        }
+    }()
+
+    return ln
+
+}
+
+// proxyStatusServer is a statusServer that requires a PROXY v2 header
+func proxyStatusServer(t *testing.T, rsp packet.Response) net.Listener {
+
+    ln, err := net.Listen("tcp", "127.0.0.1:0")
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    go func() {
+        for {
+            conn, err := ln.Accept()
+            if err != nil {
+                return
+            }
+            go func() {
+                defer conn.Close()
+                p := make([]byte, 16)
+                _, err := io.ReadFull(conn, p)
+                if err != nil || string(p[:12]) != "\r\n\r\n\x00\r\nQUIT\n" {
+                    return
+                }
+                _, err = io.ReadFull(conn, make([]byte, int(p[14]) << 8 | int(p[15])))
+                if err != nil {
+                    return
+                }
+                hs, err := packet.ReadHandshake(conn)
+                if err != nil {
+                    return
+                }
+                packet.ServeResponse(conn, hs, rsp)
+            }()
+        }
+    }()
+
+    return ln
+
+}
+
+func closedAddr(t *testing.T) string {
+    ln, err := net.Listen("tcp", "127.0.0.1:0")
+    if err != nil {
+        t.Fatal(err)
+    }
+    addr := ln.Addr().String()
+    ln.Close()
+    return addr
+}
+
+func TestStaticManager(t *testing.T) {
+
+    ln := statusServer(t, packet.Response{})
+    defer ln.Close()
+    live, dead := ln.Addr().String(), closedAddr(t)
+
+    sm, err := NewStaticManager([]string{dead, live})
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer sm.Close()
+    sm.FailThreshold = 2
+    sm.RiseThreshold = 2
+
+    if err := sm.Start(); !errors.Is(err, ErrNotSupported) {
+        t.Error("Start:", err)
+    }
+
+    state, err := sm.State()
+    if state != StateRunning || err != nil {
+        t.Fatal(state, err)
+    }
+    if sm.Addr() != live {
+        t.Error("Wrong address", sm.Addr())
+    }
+    conn, err := sm.Dial()
+    if err != nil {
+        t.Fatal(err)
+    }
+    conn.Close()
+
+    // Down only after enough failed probes
+    ln.Close()
+    sm.probe()
+    if len(sm.up()) != 1 {
+        t.Error("Down after one failure")
+    }
+    sm.probe()
+    if len(sm.up()) != 0 {
+        t.Error("Not down after two failures")
+    }
+    if _, err := sm.Dial(); err == nil {
+        t.Error("Dialed with no backend up")
+    }
+
+    // And up again after enough good ones
+    ln = statusServer(t, packet.Response{})
+    defer ln.Close()
+    sm.backends[0].addr = ln.Addr().String()
+    sm.probe()
+    if len(sm.up()) != 0 {
+        t.Error("Up after one success")
+    }
+    sm.probe()
+    if addrs := sm.up(); len(addrs) != 1 || addrs[0] != ln.Addr().String() {
+        t.Error("Not up after two successes", addrs)
+    }
+
+}
+
+func TestStaticManagerJson(t *testing.T) {
+
+    for _, data := range []string{
+        `{}`,
+        `{"backends":["localhost"]}`,
+        `{"backends":["localhost:25565"],"interval":0}`,
+    } {
+        if _, err := NewStaticManagerJson([]byte(data)); err == nil {
+            t.Errorf("%s was accepted", data)
+        }
+    }
+
+    sm, err := NewStaticManagerJson([]byte(`{"backends":["localhost:25565"],"failThreshold":5}`))
+    if err != nil || sm.FailThreshold != 5 || sm.Interval != 10 {
+        t.Fatal(sm, err)
+    }
+    sm.Close()
+
+}
+
+func TestStaticProxyProtocol(t *testing.T) {
+
+    ln := proxyStatusServer(t, packet.Response{})
+    defer ln.Close()
+
+    sm, err := NewStaticManager([]string{ln.Addr().String()})
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer sm.Close()
+    SetProxyProtocol(sm, 2)
+
+    state, err := sm.State()
+    if state != StateRunning || err != nil {
+        t.Fatal("Probe without a PROXY header:", StateName(state), err)
+    }
+
+}
+
+func TestStaticProbes(t *testing.T) {
+
+    ln := statusServer(t, packet.Response{})
+    defer ln.Close()
+
+    sm, err := NewStaticManagerJson([]byte(`{"backends":["` + ln.Addr().String() + `"],"interval":1,"failThreshold":1}`))
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer sm.Close()
+
+    // Callers during the first probes wait for them
+    states := make(chan int, 10)
+    for i := 0; i < cap(states); i++ {
+        go func() {
+            state, _ := sm.State()
+            states <- state
+        }()
+    }
+    for i := 0; i < cap(states); i++ {
+        if state := <-states; state != StateRunning {
+            t.Fatal("State during the first probes:", StateName(state))
+        }
+    }
+
+    // Later probes go on without anyone asking
+    ln.Close()
+    time.Sleep(time.Duration(sm.FailThreshold) * sm.interval() + 500 * time.Millisecond)
+    if addrs := sm.up(); len(addrs) != 0 {
+        t.Error("Still up", addrs)
+    }
+
+}
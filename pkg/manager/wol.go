@@ -0,0 +1,237 @@
+package manager
+
+import (
+    "bytes"
+    "encoding/json"
+    "fmt"
+    "net"
+    "os/exec"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+)
+
+// Wake-on-LAN
+// Wakes a machine with a magic packet and shuts it down over ssh. The
+// machine is up while its ssh port accepts connections, which needs no
+// ICMP, and the minecraft server is running while it answers statuses.
+type WOLManager struct {
+    MAC string `json:"mac"`
+    Broadcast string `json:"broadcast"` // host:port the magic packet is sent to
+    Address string `json:"address"` // host:port of the minecraft server
+    SSHAddress string `json:"sshAddress"` // host:port, the host of the address and port 22 if empty
+    SSHUser string `json:"sshUser"`
+    SSHKey string `json:"sshKey"` // identity file
+    StopCommand string `json:"stopCommand"` // e.g. "sudo poweroff", Stop is not supported if empty
+    BootTimeout int `json:"bootTimeout"` // unit: seconds, until a machine that did not wake is stopped
+    Timeout int `json:"timeout"` // unit: seconds
+    appState int
+    woken time.Time
+    prober
+    lock sync.Mutex
+}
+
+const (
+    DefaultWOLBroadcast = "255.255.255.255:9"
+    DefaultSSHPort = "22"
+)
+
+// sshPath is the ssh client run by Stop
+var sshPath = "ssh"
+
+func newWOLManager() *WOLManager {
+    return &WOLManager{
+        Broadcast: DefaultWOLBroadcast,
+        BootTimeout: 300,
+        Timeout: 5,
+        appState: StateObscure,
+    }
+}
+
+func NewWOLManager(mac, broadcast, addr string) (*WOLManager, error) {
+    wm := newWOLManager()
+    wm.MAC = mac
+    wm.Broadcast = broadcast
+    wm.Address = addr
+    return wm, wm.init()
+}
+
+func NewWOLManagerJson(data []byte) (*WOLManager, error) {
+    wm := newWOLManager()
+    err := json.Unmarshal(data, wm)
+    if err != nil {
+        return nil, err
+    }
+    return wm, wm.init()
+}
+
+func(wm *WOLManager) init() error {
+
+    if _, err := parseMAC(wm.MAC); err != nil {
+        return err
+    }
+    if _, _, err := net.SplitHostPort(wm.Broadcast); err != nil {
+        return fmt.Errorf("WOL broadcast %s: %v", wm.Broadcast, err)
+    }
+    host, _, err := net.SplitHostPort(wm.Address)
+    if err != nil {
+        return fmt.Errorf("WOL address %s: %v", wm.Address, err)
+    }
+
+    if wm.SSHAddress == "" {
+        wm.SSHAddress = net.JoinHostPort(host, DefaultSSHPort)
+    }
+    if _, _, err := net.SplitHostPort(wm.SSHAddress); err != nil {
+        return fmt.Errorf("WOL ssh address %s: %v", wm.SSHAddress, err)
+    }
+
+    return nil
+
+}
+
+func parseMAC(s string) (net.HardwareAddr, error) {
+    mac, err := net.ParseMAC(s)
+    if err != nil {
+        return nil, err
+    }
+    if len(mac) != 6 {
+        return nil, fmt.Errorf("MAC %s is not of 6 bytes", s)
+    }
+    return mac, nil
+}
+
+// magicPacket is 6 bytes of 0xff followed by the MAC 16 times
+func magicPacket(mac net.HardwareAddr) []byte {
+    p := bytes.Repeat([]byte{0xff}, 6)
+    for i := 0; i < 16; i++ {
+        p = append(p, mac...)
+    }
+    return p
+}
+
+func(wm *WOLManager) Addr() string {
+    return wm.Address
+}
+
+func(wm *WOLManager) Start() error {
+
+    wm.lock.Lock()
+    defer wm.lock.Unlock()
+
+    mac, err := parseMAC(wm.MAC)
+    if err != nil {
+        return err
+    }
+
+    conn, err := net.Dial("udp", wm.Broadcast)
+    if err != nil {
+        return err
+    }
+    defer conn.Close()
+
+    _, err = conn.Write(magicPacket(mac))
+    if err != nil {
+        return err
+    }
+
+    wm.appState = StatePending
+    wm.woken = time.Now()
+    return nil
+
+}
+
+func(wm *WOLManager) CanStart() bool {
+    return true
+}
+
+// CanStop is true with a stop command
+func(wm *WOLManager) CanStop() bool {
+    return wm.StopCommand != ""
+}
+
+func(wm *WOLManager) Stop() error {
+
+    if wm.StopCommand == "" {
+        return fmt.Errorf("%w: no stop command is configured", ErrNotSupported)
+    }
+
+    wm.lock.Lock()
+    defer wm.lock.Unlock()
+
+    out, err := exec.Command(sshPath, wm.sshArgs()...).CombinedOutput()
+    if err != nil {
+        return fmt.Errorf("Stop command failed: %v %s", err, strings.TrimSpace(string(out)))
+    }
+
+    wm.appState = StateStopping
+    return nil
+
+}
+
+func(wm *WOLManager) sshArgs() []string {
+
+    host, port, _ := net.SplitHostPort(wm.SSHAddress)
+    if wm.SSHUser != "" {
+        host = wm.SSHUser + "@" + host
+    }
+
+    args := []string{
+        "-o", "BatchMode=yes",
+        "-o", "ConnectTimeout=" + strconv.Itoa(wm.Timeout),
+        "-p", port,
+    }
+    if wm.SSHKey != "" {
+        args = append(args, "-i", wm.SSHKey)
+    }
+
+    return append(args, host, wm.StopCommand)
+
+}
+
+func(wm *WOLManager) State() (int, error) {
+
+    wm.lock.Lock()
+    defer wm.lock.Unlock()
+
+    // Machine
+    conn, err := net.DialTimeout("tcp", wm.SSHAddress, wm.timeout())
+    if err != nil {
+        if wm.appState == StatePending && time.Since(wm.woken) < wm.bootTimeout() {
+            return StatePending, nil
+        }
+        wm.appState = StateStopped
+        return StateStopped, nil
+    }
+    conn.Close()
+
+    if wm.appState == StateStopping {
+        return StateStopping, nil
+    }
+
+    // Underlying server
+    _, err = wm.status(wm.Address, wm.timeout())
+    if err == nil {
+        wm.appState = StateRunning
+        return StateRunning, nil
+    }
+    if wm.appState == StateRunning {
+        wm.appState = StateStopping
+        return StateStopping, nil
+    }
+
+    return StatePending, nil
+
+}
+
+func(wm *WOLManager) timeout() time.Duration {
+    return time.Duration(wm.Timeout) * time.Second
+}
+
+func(wm *WOLManager) bootTimeout() time.Duration {
+    return time.Duration(wm.BootTimeout) * time.Second
+}
+
+func(wm *WOLManager) Dial() (net.Conn, error) {
+    return wm.dial(wm.Address, wm.timeout())
+}
@@ -0,0 +1,150 @@
+package manager
+
+import (
+    "bytes"
+    "errors"
+    "net"
+    "testing"
+    "time"
+
+    "github.com/hjjg200/minecraft-forwarder/pkg/packet"
+)
+
+func TestMagicPacket(t *testing.T) {
+
+    mac, err := parseMAC("00:11:22:aa:bb:cc")
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    p := magicPacket(mac)
+    if len(p) != 102 || !bytes.Equal(p[:6], []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}) {
+        t.Fatalf("%x", p)
+    }
+    for i := 6; i < len(p); i += 6 {
+        if !bytes.Equal(p[i:i + 6], mac) {
+            t.Fatalf("%x", p)
+        }
+    }
+
+    for _, s := range []string{"", "00:11:22", "00:11:22:33:44:55:66:77"} {
+        if _, err := parseMAC(s); err == nil {
+            t.Errorf("%q was parsed", s)
+        }
+    }
+
+}
+
+func TestWOLManager(t *testing.T) {
+
+    // Magic packets are received by a local listener instead
+    udp, err := net.ListenPacket("udp", "127.0.0.1:0")
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer udp.Close()
+
+    wm, err := NewWOLManager("00:11:22:aa:bb:cc", udp.LocalAddr().String(), closedAddr(t))
+    if err != nil {
+        t.Fatal(err)
+    }
+    wm.SSHAddress = closedAddr(t)
+
+    expect := func(expected int) {
+        t.Helper()
+        state, err := wm.State()
+        if state != expected || err != nil {
+            t.Fatalf("Expected %s, got %s %v", StateName(expected), StateName(state), err)
+        }
+    }
+
+    expect(StateStopped)
+
+    if err := wm.Start(); err != nil {
+        t.Fatal(err)
+    }
+    p := make([]byte, 200)
+    udp.SetReadDeadline(time.Now().Add(time.Second))
+    n, _, err := udp.ReadFrom(p)
+    if err != nil {
+        t.Fatal(err)
+    }
+    mac, _ := parseMAC(wm.MAC)
+    if !bytes.Equal(p[:n], magicPacket(mac)) {
+        t.Fatalf("%x", p[:n])
+    }
+
+    // Booting until ssh and then the server are up
+    expect(StatePending)
+
+    ssh, err := net.Listen("tcp", "127.0.0.1:0")
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer ssh.Close()
+    wm.SSHAddress = ssh.Addr().String()
+    expect(StatePending)
+
+    server := statusServer(t, packet.Response{})
+    defer server.Close()
+    wm.Address = server.Addr().String()
+    expect(StateRunning)
+
+    // The server going away while the machine is up
+    server.Close()
+    expect(StateStopping)
+    ssh.Close()
+    expect(StateStopped)
+
+    // A machine that does not wake in time
+    wm.Start()
+    expect(StatePending)
+    wm.woken = time.Now().Add(-wm.bootTimeout())
+    expect(StateStopped)
+
+}
+
+func TestWOLStop(t *testing.T) {
+
+    wm, err := NewWOLManagerJson([]byte(`{"mac":"00:11:22:aa:bb:cc","address":"mc.example.com:25565"}`))
+    if err != nil {
+        t.Fatal(err)
+    }
+    if wm.SSHAddress != "mc.example.com:22" {
+        t.Error("Wrong ssh address", wm.SSHAddress)
+    }
+    if err := wm.Stop(); !errors.Is(err, ErrNotSupported) {
+        t.Error("Stop without a command:", err)
+    }
+
+    wm.SSHUser = "mc"
+    wm.SSHKey = "/home/mc/.ssh/id_ed25519"
+    wm.StopCommand = "sudo poweroff"
+    args := wm.sshArgs()
+    expected := []string{
+        "-o", "BatchMode=yes", "-o", "ConnectTimeout=5", "-p", "22",
+        "-i", "/home/mc/.ssh/id_ed25519", "mc@mc.example.com", "sudo poweroff",
+    }
+    if len(args) != len(expected) {
+        t.Fatal(args)
+    }
+    for i := range args {
+        if args[i] != expected[i] {
+            t.Fatal(args)
+        }
+    }
+
+    defer func(path string) {
+        sshPath = path
+    }(sshPath)
+
+    sshPath = "true"
+    if err := wm.Stop(); err != nil || wm.appState != StateStopping {
+        t.Error("Stop failed", err)
+    }
+    sshPath = "false"
+    if err := wm.Stop(); err == nil {
+        t.Error("Failed stop went unnoticed")
+    }
+
+}
@@ -0,0 +1,34 @@
+package metrics
+
+// Forwarder metrics
+// Servers are labeled with their configured names, never with the hostname
+// a client sent, which is arbitrary
+var (
+    Handshakes = NewCounter("minecraft_forwarder_handshakes_total",
+        "Handshakes received by next state and server", "next_state", "server")
+    UnknownHosts = NewCounter("minecraft_forwarder_unknown_host_total",
+        "Connections rejected because no server matched the hostname")
+    StartAttempts = NewCounter("minecraft_forwarder_start_attempts_total",
+        "Calls to start a server", "server")
+    StartFailures = NewCounter("minecraft_forwarder_start_failures_total",
+        "Failed calls to start a server", "server")
+    StateQueries = NewHistogram("minecraft_forwarder_state_query_seconds",
+        "Latency of server state queries", DefaultBuckets, "server")
+    StateErrors = NewCounter("minecraft_forwarder_state_query_errors_total",
+        "Failed server state queries", "server")
+    States = NewGauge("minecraft_forwarder_server_state",
+        "Last known state of a server: -1 obscure, 0 stopped, 1 pending, 2 running, 3 stopping", "server")
+    Sessions = NewGauge("minecraft_forwarder_sessions",
+        "Active forwarded sessions", "server")
+    Bytes = NewCounter("minecraft_forwarder_bytes_total",
+        "Bytes forwarded by direction, up being client to server", "server", "direction")
+)
+
+func NextStateName(state int32) string {
+    switch state {
+    case 1: return "status"
+    case 2: return "login"
+    case 3: return "transfer"
+    }
+    return "unknown"
+}
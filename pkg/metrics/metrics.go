@@ -0,0 +1,293 @@
+package metrics
+
+import (
+    "fmt"
+    "io"
+    "math"
+    "net/http"
+    "sort"
+    "strconv"
+    "strings"
+    "sync"
+    "sync/atomic"
+)
+
+// Prometheus text exposition format
+// https://prometheus.io/docs/instrumenting/exposition_formats/
+const (
+    kindCounter = "counter"
+    kindGauge = "gauge"
+    kindHistogram = "histogram"
+)
+
+// Value
+// Single float that can be updated concurrently
+type Value struct {
+    bits uint64
+}
+
+func(v *Value) Add(x float64) {
+    for {
+        old := atomic.LoadUint64(&v.bits)
+        neu := math.Float64bits(math.Float64frombits(old) + x)
+        if atomic.CompareAndSwapUint64(&v.bits, old, neu) {
+            return
+        }
+    }
+}
+
+func(v *Value) Set(x float64) {
+    atomic.StoreUint64(&v.bits, math.Float64bits(x))
+}
+
+func(v *Value) Inc() {
+    v.Add(1)
+}
+
+func(v *Value) Dec() {
+    v.Add(-1)
+}
+
+func(v *Value) Get() float64 {
+    return math.Float64frombits(atomic.LoadUint64(&v.bits))
+}
+
+// histogram
+type histogram struct {
+    counts []Value // per bucket, not cumulative
+    sum Value
+    count Value
+}
+
+// Vec
+// Metric with labels
+type Vec struct {
+    name string
+    help string
+    kind string
+    labels []string
+    buckets []float64
+    values map[string] *Value
+    histograms map[string] *histogram
+    keys map[string] []string
+    lock sync.Mutex
+}
+
+// Registry
+type Registry struct {
+    vecs []*Vec
+    lock sync.Mutex
+}
+
+var DefaultRegistry = &Registry{}
+
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+func(r *Registry) register(name, help, kind string, labels []string) *Vec {
+
+    vec := &Vec{
+        name: name,
+        help: help,
+        kind: kind,
+        labels: labels,
+        values: make(map[string] *Value),
+        histograms: make(map[string] *histogram),
+        keys: make(map[string] []string),
+    }
+
+    r.lock.Lock()
+    r.vecs = append(r.vecs, vec)
+    r.lock.Unlock()
+
+    return vec
+
+}
+
+func(r *Registry) NewCounter(name, help string, labels ...string) *Vec {
+    return r.register(name, help, kindCounter, labels)
+}
+
+func(r *Registry) NewGauge(name, help string, labels ...string) *Vec {
+    return r.register(name, help, kindGauge, labels)
+}
+
+func(r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *Vec {
+    vec := r.register(name, help, kindHistogram, labels)
+    vec.buckets = buckets
+    return vec
+}
+
+func NewCounter(name, help string, labels ...string) *Vec {
+    return DefaultRegistry.NewCounter(name, help, labels...)
+}
+
+func NewGauge(name, help string, labels ...string) *Vec {
+    return DefaultRegistry.NewGauge(name, help, labels...)
+}
+
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Vec {
+    return DefaultRegistry.NewHistogram(name, help, buckets, labels...)
+}
+
+func(vec *Vec) key(lvs []string) string {
+    if len(lvs) != len(vec.labels) {
+        panic(fmt.Sprintf("Metric %s expects %d label values", vec.name, len(vec.labels)))
+    }
+    return strings.Join(lvs, "\x00")
+}
+
+// With returns the value for the label values, in the order of the labels
+func(vec *Vec) With(lvs ...string) *Value {
+
+    k := vec.key(lvs)
+
+    vec.lock.Lock()
+    defer vec.lock.Unlock()
+
+    v, ok := vec.values[k]
+    if !ok {
+        v = &Value{}
+        vec.values[k] = v
+        vec.keys[k] = append([]string{}, lvs...)
+    }
+
+    return v
+
+}
+
+func(vec *Vec) Observe(x float64, lvs ...string) {
+
+    k := vec.key(lvs)
+
+    vec.lock.Lock()
+    h, ok := vec.histograms[k]
+    if !ok {
+        h = &histogram{counts: make([]Value, len(vec.buckets))}
+        vec.histograms[k] = h
+        vec.keys[k] = append([]string{}, lvs...)
+    }
+    vec.lock.Unlock()
+
+    for i, le := range vec.buckets {
+        if x <= le {
+            h.counts[i].Inc()
+            break
+        }
+    }
+    h.sum.Add(x)
+    h.count.Inc()
+
+}
+
+func(vec *Vec) Inc(lvs ...string) {
+    vec.With(lvs...).Inc()
+}
+
+func(vec *Vec) Dec(lvs ...string) {
+    vec.With(lvs...).Dec()
+}
+
+func(vec *Vec) Add(x float64, lvs ...string) {
+    vec.With(lvs...).Add(x)
+}
+
+func(vec *Vec) Set(x float64, lvs ...string) {
+    vec.With(lvs...).Set(x)
+}
+
+func formatFloat(x float64) string {
+    switch {
+    case math.IsInf(x, 1): return "+Inf"
+    case math.IsInf(x, -1): return "-Inf"
+    }
+    return strconv.FormatFloat(x, 'g', -1, 64)
+}
+
+func escapeLabel(s string) string {
+    s = strings.ReplaceAll(s, `\`, `\\`)
+    s = strings.ReplaceAll(s, "\n", `\n`)
+    return strings.ReplaceAll(s, `"`, `\"`)
+}
+
+func(vec *Vec) labelString(lvs []string, extra ...string) string {
+
+    pairs := make([]string, 0, len(lvs) + 1)
+    for i, lv := range lvs {
+        pairs = append(pairs, fmt.Sprintf(`%s="%s"`, vec.labels[i], escapeLabel(lv)))
+    }
+    for i := 0; i + 1 < len(extra); i += 2 {
+        pairs = append(pairs, fmt.Sprintf(`%s="%s"`, extra[i], escapeLabel(extra[i + 1])))
+    }
+
+    if len(pairs) == 0 {
+        return ""
+    }
+    return "{" + strings.Join(pairs, ",") + "}"
+
+}
+
+func(vec *Vec) write(w io.Writer) {
+
+    vec.lock.Lock()
+    defer vec.lock.Unlock()
+
+    fmt.Fprintf(w, "# HELP %s %s\n", vec.name, vec.help)
+    fmt.Fprintf(w, "# TYPE %s %s\n", vec.name, vec.kind)
+
+    ks := make([]string, 0, len(vec.keys))
+    for k := range vec.keys {
+        ks = append(ks, k)
+    }
+    sort.Strings(ks)
+
+    for _, k := range ks {
+        lvs := vec.keys[k]
+
+        if vec.kind != kindHistogram {
+            fmt.Fprintf(w, "%s%s %s\n", vec.name, vec.labelString(lvs), formatFloat(vec.values[k].Get()))
+            continue
+        }
+
+        h := vec.histograms[k]
+        cumulative := 0.0
+        for i, le := range vec.buckets {
+            cumulative += h.counts[i].Get()
+            fmt.Fprintf(w, "%s_bucket%s %s\n",
+                vec.name, vec.labelString(lvs, "le", formatFloat(le)), formatFloat(cumulative))
+        }
+        fmt.Fprintf(w, "%s_bucket%s %s\n",
+            vec.name, vec.labelString(lvs, "le", "+Inf"), formatFloat(h.count.Get()))
+        fmt.Fprintf(w, "%s_sum%s %s\n", vec.name, vec.labelString(lvs), formatFloat(h.sum.Get()))
+        fmt.Fprintf(w, "%s_count%s %s\n", vec.name, vec.labelString(lvs), formatFloat(h.count.Get()))
+    }
+
+}
+
+func(r *Registry) Expose(w io.Writer) {
+
+    r.lock.Lock()
+    vecs := append([]*Vec{}, r.vecs...)
+    r.lock.Unlock()
+
+    for _, vec := range vecs {
+        vec.write(w)
+    }
+
+}
+
+func(r *Registry) Handler() http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+        w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+        r.Expose(w)
+    })
+}
+
+func Handler() http.Handler {
+    return DefaultRegistry.Handler()
+}
+
+func ListenAndServe(addr string) error {
+    mux := http.NewServeMux()
+    mux.Handle("/metrics", Handler())
+    return http.ListenAndServe(addr, mux)
+}
@@ -0,0 +1,44 @@
+package metrics
+
+import (
+    "bytes"
+    "testing"
+)
+
+func TestExpose(t *testing.T) {
+
+    r := &Registry{}
+    counter := r.NewCounter("test_total", "Test counter", "server")
+    gauge := r.NewGauge("test_gauge", "Test gauge")
+    hist := r.NewHistogram("test_seconds", "Test histogram", []float64{0.1, 1}, "server")
+
+    counter.Inc("a")
+    counter.Add(2, `b"c`)
+    gauge.Set(-1)
+    hist.Observe(0.05, "a")
+    hist.Observe(0.5, "a")
+    hist.Observe(5, "a")
+
+    expected := `# HELP test_total Test counter
+# TYPE test_total counter
+test_total{server="a"} 1
+test_total{server="b\"c"} 2
+# HELP test_gauge Test gauge
+# TYPE test_gauge gauge
+test_gauge -1
+# HELP test_seconds Test histogram
+# TYPE test_seconds histogram
+test_seconds_bucket{server="a",le="0.1"} 1
+test_seconds_bucket{server="a",le="1"} 2
+test_seconds_bucket{server="a",le="+Inf"} 3
+test_seconds_sum{server="a"} 5.55
+test_seconds_count{server="a"} 3
+`
+
+    var buf bytes.Buffer
+    r.Expose(&buf)
+    if buf.String() != expected {
+        t.Errorf("\n%s\n!=\n%s", buf.String(), expected)
+    }
+
+}
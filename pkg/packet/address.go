@@ -0,0 +1,66 @@
+package packet
+
+import (
+    "strings"
+)
+
+// HandshakeAddress
+// The address in a handshake carries more than the hostname for some
+// clients and proxies, the fields being separated by NUL:
+//   mc.example.com\0FML\0                  Forge 1.7 - 1.12
+//   mc.example.com\0FML2\0                 Forge 1.13 - 1.17
+//   mc.example.com\0FML3\0                 Forge 1.18 - 1.20.1
+//   mc.example.com\0ip\0uuid\0properties   BungeeCord player info
+type HandshakeAddress struct {
+    Hostname string
+    Markers []string // of mod loaders, e.g. FML2
+    Forwarding []string // player info put in by a proxy
+}
+
+// modLoaderMarkers are the prefixes of markers, which may be followed by
+// a network version
+var modLoaderMarkers = []string{"FML", "FORGE", "NEOFORGE"}
+
+func isModLoaderMarker(s string) bool {
+    for _, prefix := range modLoaderMarkers {
+        if !strings.HasPrefix(s, prefix) {
+            continue
+        }
+        version := s[len(prefix):]
+        if strings.Trim(version, "0123456789") == "" {
+            return true
+        }
+    }
+    return false
+}
+
+func ParseAddress(s string) HandshakeAddress {
+
+    fields := strings.Split(s, "\x00")
+    addr := HandshakeAddress{Hostname: fields[0]}
+
+    for _, field := range fields[1:] {
+        switch {
+        case field == "":
+        case isModLoaderMarker(field):
+            addr.Markers = append(addr.Markers, field)
+        default:
+            addr.Forwarding = append(addr.Forwarding, field)
+        }
+    }
+
+    return addr
+
+}
+
+// Modded reports whether the client has a mod loader
+func(addr HandshakeAddress) Modded() bool {
+    return len(addr.Markers) > 0
+}
+
+// Hostname is the address without markers or forwarded info, which is
+// what servers are to be found by. The address itself is kept as is so
+// that it is forwarded with the markers.
+func(hs Handshake) Hostname() string {
+    return ParseAddress(hs.Address).Hostname
+}
@@ -0,0 +1,53 @@
+package packet
+
+import (
+    "bytes"
+    "reflect"
+    "testing"
+)
+
+func TestParseAddress(t *testing.T) {
+
+    samples := []struct {
+        in string
+        expected HandshakeAddress
+    }{
+        {"mc.example.com", HandshakeAddress{"mc.example.com", nil, nil}},
+        {"mc.example.com\x00FML\x00", HandshakeAddress{"mc.example.com", []string{"FML"}, nil}},
+        {"mc.example.com\x00FML2\x00", HandshakeAddress{"mc.example.com", []string{"FML2"}, nil}},
+        {"mc.example.com\x00FML3\x00", HandshakeAddress{"mc.example.com", []string{"FML3"}, nil}},
+        {"mc.example.com\x00FORGE", HandshakeAddress{"mc.example.com", []string{"FORGE"}, nil}},
+        {
+            "mc.example.com\x00127.0.0.1\x00b5a1c4a1e5b34d1f9a6b7c8d9e0f1a2b\x00[]",
+            HandshakeAddress{"mc.example.com", nil, []string{"127.0.0.1", "b5a1c4a1e5b34d1f9a6b7c8d9e0f1a2b", "[]"}},
+        },
+        {"mc.example.com\x00FMLX\x00", HandshakeAddress{"mc.example.com", nil, []string{"FMLX"}}},
+        {"", HandshakeAddress{"", nil, nil}},
+    }
+
+    for _, sample := range samples {
+        addr := ParseAddress(sample.in)
+        if !reflect.DeepEqual(addr, sample.expected) {
+            t.Errorf("%q: expected %+v, got %+v", sample.in, sample.expected, addr)
+        }
+    }
+
+}
+
+func TestHandshakeHostname(t *testing.T) {
+
+    hs := Handshake{767, "mc.example.com\x00FML3\x00", 25565, StateLogin, false}
+    if hs.Hostname() != "mc.example.com" {
+        t.Error("Wrong hostname", hs.Hostname())
+    }
+    if !ParseAddress(hs.Address).Modded() {
+        t.Error("Markers were not found")
+    }
+
+    // Forwarded as is
+    hs1, err := ReadHandshake(bytes.NewReader(hs.Bytes()))
+    if err != nil || hs1 != hs {
+        t.Errorf("%+v != %+v, %v", hs1, hs, err)
+    }
+
+}
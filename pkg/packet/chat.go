@@ -1,40 +1,303 @@
 package packet
 
 import (
+    "bytes"
     "encoding/json"
+    "fmt"
+    "strings"
 )
 
-type ChatElem struct {
-    Text string `json:"text"`
-    Italic bool `json:"italic"`
-    Underlined bool `json:"underlined"`
-    Strikethrough bool `json:"strikethrough"`
-    Obfuscated bool `json:"obfuscated"`
-    Bold bool `json:"bold"`
-    Color string `json:"color"`
+// Chat
+// Text component as used by every version since 1.7. A component has one
+// kind of content, text being the default, then style and children, which
+// inherit the style.
+type Chat struct {
+
+    // Content
+    Text string `json:"text,omitempty"`
+    Translate string `json:"translate,omitempty"`
+    With []Chat `json:"with,omitempty"` // arguments of translate
+    Keybind string `json:"keybind,omitempty"`
+    Score *Score `json:"score,omitempty"`
+    Selector string `json:"selector,omitempty"`
+    Separator *Chat `json:"separator,omitempty"` // of selector
+
+    // Style, nil style booleans inherit from the parent
+    Color string `json:"color,omitempty"` // name or #rrggbb since 1.16
+    Font string `json:"font,omitempty"`
+    Bold *bool `json:"bold,omitempty"`
+    Italic *bool `json:"italic,omitempty"`
+    Underlined *bool `json:"underlined,omitempty"`
+    Strikethrough *bool `json:"strikethrough,omitempty"`
+    Obfuscated *bool `json:"obfuscated,omitempty"`
+    Insertion string `json:"insertion,omitempty"`
+    ClickEvent *ClickEvent `json:"clickEvent,omitempty"`
+    HoverEvent *HoverEvent `json:"hoverEvent,omitempty"`
+
+    Extra []Chat `json:"extra,omitempty"`
+
 }
 
-type Chat struct {
-    ChatElem
-    Extra []ChatElem `json:"Extra"`
+type Score struct {
+    Name string `json:"name"`
+    Objective string `json:"objective"`
+}
+
+// ClickEvent actions: open_url, run_command, suggest_command, change_page,
+// copy_to_clipboard
+type ClickEvent struct {
+    Action string `json:"action"`
+    Value string `json:"value"`
+}
+
+// HoverEvent actions: show_text, show_item, show_entity
+// Contents is used since 1.16, Value before that
+type HoverEvent struct {
+    Action string `json:"action"`
+    Contents json.RawMessage `json:"contents,omitempty"`
+    Value json.RawMessage `json:"value,omitempty"`
+}
+
+// ShowText is a hover event that shows the chat in both of its forms
+func ShowText(c Chat) *HoverEvent {
+    p := c.Bytes()
+    return &HoverEvent{
+        Action: "show_text",
+        Contents: p,
+        Value: p,
+    }
+}
+
+// Bool is a helper for the style booleans
+func Bool(b bool) *bool {
+    return &b
+}
+
+// ChatColors are the named colors, by their legacy code
+var ChatColors = []struct {
+    Code byte
+    Name string
+    RGB uint32
+}{
+    {'0', "black", 0x000000},
+    {'1', "dark_blue", 0x0000aa},
+    {'2', "dark_green", 0x00aa00},
+    {'3', "dark_aqua", 0x00aaaa},
+    {'4', "dark_red", 0xaa0000},
+    {'5', "dark_purple", 0xaa00aa},
+    {'6', "gold", 0xffaa00},
+    {'7', "gray", 0xaaaaaa},
+    {'8', "dark_gray", 0x555555},
+    {'9', "blue", 0x5555ff},
+    {'a', "green", 0x55ff55},
+    {'b', "aqua", 0x55ffff},
+    {'c', "red", 0xff5555},
+    {'d', "light_purple", 0xff55ff},
+    {'e', "yellow", 0xffff55},
+    {'f', "white", 0xffffff},
+}
+
+// ParseColor returns the rgb of a named or #rrggbb color
+func ParseColor(color string) (uint32, error) {
+
+    for _, each := range ChatColors {
+        if each.Name == color {
+            return each.RGB, nil
+        }
+    }
+
+    var rgb uint32
+    if len(color) == 7 && color[0] == '#' {
+        _, err := fmt.Sscanf(color[1:], "%06x", &rgb)
+        if err == nil {
+            return rgb, nil
+        }
+    }
+
+    return 0, fmt.Errorf("Unknown color %s", color)
+
+}
+
+// NearestColor returns the named color closest to the color
+func NearestColor(color string) string {
+
+    rgb, err := ParseColor(color)
+    if err != nil {
+        return ""
+    }
+
+    channel := func(c uint32, shift uint) int {
+        return int(c >> shift & 0xff)
+    }
+    best, bestDist := "", -1
+    for _, each := range ChatColors {
+        dist := 0
+        for _, shift := range []uint{16, 8, 0} {
+            d := channel(rgb, shift) - channel(each.RGB, shift)
+            dist += d * d
+        }
+        if bestDist < 0 || dist < bestDist {
+            best, bestDist = each.Name, dist
+        }
+    }
+
+    return best
+
+}
+
+const ProtocolHexColor = 735 // 1.16
+
+// Downsample replaces the hex colors that clients before 1.16 do not know
+// with the nearest named ones
+func(c Chat) Downsample() Chat {
+
+    if strings.HasPrefix(c.Color, "#") {
+        c.Color = NearestColor(c.Color)
+    }
+
+    c.With = downsampleAll(c.With)
+    c.Extra = downsampleAll(c.Extra)
+    if c.Separator != nil {
+        sep := c.Separator.Downsample()
+        c.Separator = &sep
+    }
+
+    return c
+
+}
+
+func downsampleAll(cs []Chat) []Chat {
+    if cs == nil {
+        return nil
+    }
+    out := make([]Chat, len(cs))
+    for i := range cs {
+        out[i] = cs[i].Downsample()
+    }
+    return out
 }
 
 func ReadChat(pr *PacketReader) (Chat, error) {
-    data := pr.NextString()
     var c Chat
+    data := pr.NextString()
+    if pr.Err() != nil {
+        return c, pr.Err()
+    }
     err := json.Unmarshal([]byte(data), &c)
     return c, err
 }
 
+// chatJSON has the fields of Chat without its methods
+type chatJSON Chat
+
+func(c Chat) hasContent() bool {
+    return c.Translate != "" || c.Keybind != "" || c.Score != nil || c.Selector != ""
+}
+
+func(c Chat) MarshalJSON() ([]byte, error) {
+
+    if c.hasContent() {
+        return json.Marshal(chatJSON(c))
+    }
+
+    // Text is required when there is no other content, even if empty
+    return json.Marshal(struct {
+        Text string `json:"text"`
+        chatJSON
+    }{c.Text, chatJSON(c)})
+
+}
+
+// UnmarshalJSON accepts the object form as well as plain strings and
+// arrays, of which the first element is the parent of the rest
+func(c *Chat) UnmarshalJSON(data []byte) error {
+
+    data = bytes.TrimSpace(data)
+    if len(data) == 0 {
+        return fmt.Errorf("Empty chat")
+    }
+
+    switch data[0] {
+    case '"':
+        *c = Chat{}
+        return json.Unmarshal(data, &c.Text)
+    case '[':
+        var cs []Chat
+        err := json.Unmarshal(data, &cs)
+        if err != nil {
+            return err
+        }
+        if len(cs) == 0 {
+            return fmt.Errorf("Empty chat array")
+        }
+        *c = cs[0]
+        c.Extra = append(c.Extra, cs[1:]...)
+        return nil
+    case '{':
+        var cj chatJSON
+        err := json.Unmarshal(data, &cj)
+        *c = Chat(cj)
+        return err
+    }
+
+    // Numbers and booleans, which appear as translate arguments
+    *c = Chat{Text: string(data)}
+    return nil
+
+}
+
+// String returns the plain text of the chat, translate components being
+// shown by their key
 func(c Chat) String() string {
-    t := ""
-    t += c.Text
+    t := c.content()
     for _, sib := range c.Extra {
-        t += sib.Text
+        t += sib.String()
     }
     return t
 }
 
+// content is the text of the component without its children
+func(c Chat) content() string {
+    switch {
+    case c.Translate != "":
+        return c.Translate
+    case c.Keybind != "":
+        return c.Keybind
+    case c.Selector != "":
+        return c.Selector
+    case c.Score != nil:
+        return c.Score.Name
+    }
+    return c.Text
+}
+
+// FirstLine returns the chat up to its first line break
+func(c Chat) FirstLine() Chat {
+    out, _ := c.firstLine()
+    return out
+}
+
+func(c Chat) firstLine() (Chat, bool) {
+
+    out := c
+    out.Extra = nil
+    if i := strings.Index(c.Text, "\n"); i >= 0 {
+        out.Text = c.Text[:i]
+        return out, true
+    }
+
+    for _, sib := range c.Extra {
+        sib, done := sib.firstLine()
+        out.Extra = append(out.Extra, sib)
+        if done {
+            return out, true
+        }
+    }
+
+    return out, false
+
+}
+
 func(c Chat) Bytes() []byte {
     p, err := json.Marshal(c)
     if err != nil {
@@ -42,3 +305,19 @@ func(c Chat) Bytes() []byte {
     }
     return p
 }
+
+// NBT encodes the chat as a nameless NBT compound, which is how text
+// components are sent in the configuration and play states of 1.20.3+
+func(c Chat) NBT() []byte {
+
+    var v interface{}
+    dec := json.NewDecoder(bytes.NewReader(c.Bytes()))
+    dec.UseNumber()
+    err := dec.Decode(&v)
+    if err != nil {
+        return []byte{tagCompound, tagEnd}
+    }
+
+    return AppendNBT(nil, v)
+
+}
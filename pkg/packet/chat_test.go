@@ -0,0 +1,91 @@
+package packet
+
+import (
+    "bytes"
+    "encoding/json"
+    "testing"
+)
+
+func TestChatJSON(t *testing.T) {
+
+    samples := map[string] string{
+        `"plain"`: `{"text":"plain"}`,
+        `{"text":""}`: `{"text":""}`,
+        `["a",{"text":"b","bold":true}]`: `{"text":"a","extra":[{"text":"b","bold":true}]}`,
+        `{"text":"x","bold":false,"extra":["y"]}`: `{"text":"x","bold":false,"extra":[{"text":"y"}]}`,
+        `{"translate":"chat.type.text","with":["Steve",{"text":"hi","color":"#ff0000"}]}`:
+            `{"translate":"chat.type.text","with":[{"text":"Steve"},{"text":"hi","color":"#ff0000"}]}`,
+        `{"translate":"x","with":[3,true]}`: `{"translate":"x","with":[{"text":"3"},{"text":"true"}]}`,
+        `{"keybind":"key.jump"}`: `{"keybind":"key.jump"}`,
+        `{"score":{"name":"@p","objective":"kills"}}`: `{"score":{"name":"@p","objective":"kills"}}`,
+        `{"selector":"@a","separator":", "}`: `{"selector":"@a","separator":{"text":", "}}`,
+        `{"text":"c","font":"minecraft:uniform","insertion":"i","clickEvent":{"action":"open_url","value":"https://example.com"}}`:
+            `{"text":"c","font":"minecraft:uniform","insertion":"i","clickEvent":{"action":"open_url","value":"https://example.com"}}`,
+        `{"text":"h","hoverEvent":{"action":"show_text","contents":{"text":"tip"}}}`:
+            `{"text":"h","hoverEvent":{"action":"show_text","contents":{"text":"tip"}}}`,
+    }
+
+    for in, expected := range samples {
+        var c Chat
+        err := json.Unmarshal([]byte(in), &c)
+        if err != nil {
+            t.Errorf("%s) %v", in, err)
+            continue
+        }
+        out := string(c.Bytes())
+        t.Logf("%s) %s", in, out)
+        if out != expected {
+            t.Errorf("%s != %s", out, expected)
+        }
+    }
+
+}
+
+func TestChatFirstLine(t *testing.T) {
+
+    c := Chat{
+        Text: "A",
+        Extra: []Chat{
+            {Text: "B", Extra: []Chat{{Text: "C\nD"}}},
+            {Text: "E"},
+        },
+    }
+
+    first := c.FirstLine()
+    if first.String() != "ABC" {
+        t.Errorf("%q", first.String())
+    }
+
+}
+
+func TestChatDownsample(t *testing.T) {
+
+    c := Chat{Color: "#fe5050", Extra: []Chat{{Color: "#0000a0"}, {Color: "gold"}}}
+    c = c.Downsample()
+
+    if c.Color != "red" || c.Extra[0].Color != "dark_blue" || c.Extra[1].Color != "gold" {
+        t.Errorf("%s", c.Bytes())
+    }
+
+}
+
+func TestChatNBT(t *testing.T) {
+
+    c := Chat{Text: "Hi", Bold: Bool(true), Extra: []Chat{{Text: "!"}}}
+
+    expected := []byte{
+        tagCompound,
+        tagByte, 0, 4, 'b', 'o', 'l', 'd', 1,
+        tagList, 0, 5, 'e', 'x', 't', 'r', 'a', tagCompound, 0, 0, 0, 1,
+            tagString, 0, 4, 't', 'e', 'x', 't', 0, 1, '!',
+            tagEnd,
+        tagString, 0, 4, 't', 'e', 'x', 't', 0, 2, 'H', 'i',
+        tagEnd,
+    }
+
+    got := c.NBT()
+    if !bytes.Equal(got, expected) {
+        t.Errorf("%v != %v", got, expected)
+    }
+
+}
@@ -0,0 +1,41 @@
+package packet
+
+import (
+    "bytes"
+    "encoding/base64"
+    "fmt"
+    "image/png"
+    "io/ioutil"
+)
+
+const (
+    FaviconSize = 64
+    faviconPrefix = "data:image/png;base64,"
+)
+
+// LoadFavicon reads a 64x64 PNG and encodes it for Response.Favicon
+func LoadFavicon(path string) (string, error) {
+
+    data, err := ioutil.ReadFile(path)
+    if err != nil {
+        return "", err
+    }
+
+    cfg, err := png.DecodeConfig(bytes.NewReader(data))
+    if err != nil {
+        return "", fmt.Errorf("Favicon %s is not a PNG: %v", path, err)
+    }
+    if cfg.Width != FaviconSize || cfg.Height != FaviconSize {
+        return "", fmt.Errorf("Favicon %s is %dx%d, not %dx%d",
+            path, cfg.Width, cfg.Height, FaviconSize, FaviconSize)
+    }
+
+    // Decode it all so that truncated files are caught
+    _, err = png.Decode(bytes.NewReader(data))
+    if err != nil {
+        return "", fmt.Errorf("Favicon %s is broken: %v", path, err)
+    }
+
+    return faviconPrefix + base64.StdEncoding.EncodeToString(data), nil
+
+}
@@ -0,0 +1,50 @@
+package packet
+
+import (
+    "bytes"
+    "image"
+    "image/png"
+    "io/ioutil"
+    "path/filepath"
+    "strings"
+    "testing"
+)
+
+func TestLoadFavicon(t *testing.T) {
+
+    dir := t.TempDir()
+    write := func(name string, p []byte) string {
+        path := filepath.Join(dir, name)
+        if err := ioutil.WriteFile(path, p, 0600); err != nil {
+            t.Fatal(err)
+        }
+        return path
+    }
+    encode := func(w, h int) []byte {
+        var buf bytes.Buffer
+        png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, w, h)))
+        return buf.Bytes()
+    }
+
+    valid := encode(64, 64)
+    samples := map[string] bool{
+        write("valid.png", valid): true,
+        write("small.png", encode(32, 32)): false,
+        write("truncated.png", valid[:len(valid) - 20]): false,
+        write("text.png", []byte("not a png")): false,
+        filepath.Join(dir, "missing.png"): false,
+    }
+
+    for path, expected := range samples {
+        favicon, err := LoadFavicon(path)
+        result := (err == nil) == expected
+        t.Logf("%s) %v, %t", filepath.Base(path), err, result)
+        if !result {
+            t.Fail()
+        }
+        if err == nil && !strings.HasPrefix(favicon, "data:image/png;base64,") {
+            t.Error("Wrong prefix")
+        }
+    }
+
+}
@@ -0,0 +1,388 @@
+package packet
+
+import (
+    "fmt"
+    "strings"
+)
+
+// Format
+// Parses the formatting operators write, which is a mix of legacy codes,
+// & or §, and MiniMessage style tags:
+//   &6Server &lname          legacy colors and formats, &#rrggbb
+//   <gold>, <color:#ff8800>  colors, closed by </gold> or </>
+//   <bold>, <b>, <!bold>     formats and their negations
+//   <gradient:red:#00ff00>   per character colors over the enclosed text
+//   <reset>, <newline>       \< is a literal <
+// Unknown tags are kept as text
+type formatFrame struct {
+    name string
+    color bool
+    style Chat
+    gradient []uint32
+    from int // first segment of a gradient
+}
+
+type formatter struct {
+    segments []Chat
+    stack []formatFrame
+    text strings.Builder
+}
+
+var formatNames = map[string] string{
+    "bold": "bold", "b": "bold",
+    "italic": "italic", "i": "italic", "em": "italic",
+    "underlined": "underlined", "u": "underlined",
+    "strikethrough": "strikethrough", "st": "strikethrough",
+    "obfuscated": "obfuscated", "obf": "obfuscated",
+}
+
+func setFormat(style *Chat, name string, on *bool) {
+    switch name {
+    case "bold": style.Bold = on
+    case "italic": style.Italic = on
+    case "underlined": style.Underlined = on
+    case "strikethrough": style.Strikethrough = on
+    case "obfuscated": style.Obfuscated = on
+    }
+}
+
+// ParseFormat turns formatted text into a chat whose parts are children of
+// an empty parent, so the parent may carry a default style
+func ParseFormat(s string) (Chat, error) {
+
+    f := &formatter{
+        stack: []formatFrame{{}},
+    }
+
+    rs := []rune(s)
+    for i := 0; i < len(rs); i++ {
+        r := rs[i]
+        switch {
+        case r == '\\' && i + 1 < len(rs) && rs[i + 1] == '<':
+            f.text.WriteRune('<')
+            i++
+        case (r == '&' || r == '§') && i + 1 < len(rs):
+            n, ok := f.legacy(rs[i + 1:])
+            if !ok {
+                f.text.WriteRune(r)
+                continue
+            }
+            i += n
+        case r == '<':
+            end := strings.IndexRune(string(rs[i:]), '>')
+            if end < 0 {
+                f.text.WriteRune(r)
+                continue
+            }
+            tag := string(rs[i + 1:])[:end - 1]
+            ok, err := f.tag(tag)
+            if err != nil {
+                return Chat{}, err
+            }
+            if !ok {
+                f.text.WriteRune(r)
+                continue
+            }
+            i += len([]rune(tag)) + 1
+        default:
+            f.text.WriteRune(r)
+        }
+    }
+
+    f.flush()
+    for len(f.stack) > 1 {
+        f.pop()
+    }
+
+    return Chat{Extra: f.segments}, nil
+
+}
+
+func(f *formatter) top() *formatFrame {
+    return &f.stack[len(f.stack) - 1]
+}
+
+// flush ends the text written so far as a segment of the current style
+func(f *formatter) flush() {
+    if f.text.Len() == 0 {
+        return
+    }
+    seg := f.top().style
+    seg.Text = f.text.String()
+    f.segments = append(f.segments, seg)
+    f.text.Reset()
+}
+
+func(f *formatter) push(frame formatFrame) {
+    f.flush()
+    frame.from = len(f.segments)
+    f.stack = append(f.stack, frame)
+}
+
+func(f *formatter) pop() {
+    f.flush()
+    frame := f.stack[len(f.stack) - 1]
+    f.stack = f.stack[:len(f.stack) - 1]
+    if frame.gradient != nil {
+        f.segments = append(f.segments[:frame.from], applyGradient(f.segments[frame.from:], frame.gradient)...)
+    }
+}
+
+// legacy applies the code at the start of rs, reporting the runes it used
+func(f *formatter) legacy(rs []rune) (int, bool) {
+
+    code := rs[0]
+    if code >= 'A' && code <= 'Z' {
+        code += 'a' - 'A'
+    }
+
+    // &#rrggbb
+    if code == '#' && len(rs) >= 7 {
+        color := "#" + string(rs[1:7])
+        if _, err := ParseColor(color); err == nil {
+            f.flush()
+            f.top().style = Chat{Color: color}
+            return 7, true
+        }
+        return 0, false
+    }
+
+    for _, each := range ChatColors {
+        if rune(each.Code) == code {
+            // Colors reset the formats, as in vanilla
+            f.flush()
+            f.top().style = Chat{Color: each.Name}
+            return 1, true
+        }
+    }
+
+    name := ""
+    switch code {
+    case 'k': name = "obfuscated"
+    case 'l': name = "bold"
+    case 'm': name = "strikethrough"
+    case 'n': name = "underlined"
+    case 'o': name = "italic"
+    case 'r':
+        f.flush()
+        f.top().style = Chat{}
+        return 1, true
+    default:
+        return 0, false
+    }
+
+    f.flush()
+    setFormat(&f.top().style, name, Bool(true))
+    return 1, true
+
+}
+
+// tag applies a tag without its brackets, reporting whether it is known
+func(f *formatter) tag(tag string) (bool, error) {
+
+    // Closing
+    if strings.HasPrefix(tag, "/") {
+        name := strings.ToLower(strings.SplitN(tag[1:], ":", 2)[0])
+        if formatNames[name] != "" {
+            name = formatNames[name]
+        }
+        anyColor := name == "color" || name == "colour" || name == "c"
+        for i := len(f.stack) - 1; i > 0; i-- {
+            frame := f.stack[i]
+            if name == "" || frame.name == name || (anyColor && frame.color) {
+                for len(f.stack) > i {
+                    f.pop()
+                }
+                return true, nil
+            }
+        }
+
+        // Unmatched, dropped if it is a known tag
+        _, err := ParseColor(name)
+        return anyColor || err == nil || formatNames[name] != "" || name == "gradient", nil
+    }
+
+    args := strings.Split(tag, ":")
+    name := strings.ToLower(args[0])
+    style := f.top().style
+
+    switch {
+    case name == "reset":
+        f.flush()
+        for len(f.stack) > 1 {
+            f.pop()
+        }
+        f.top().style = Chat{}
+        return true, nil
+    case name == "newline" || name == "br":
+        f.text.WriteRune('\n')
+        return true, nil
+    case name == "color" || name == "colour" || name == "c":
+        if len(args) != 2 {
+            return false, fmt.Errorf("Tag <%s> needs one color", tag)
+        }
+        if _, err := ParseColor(strings.ToLower(args[1])); err != nil {
+            return false, fmt.Errorf("Tag <%s>: %v", tag, err)
+        }
+        style.Color = strings.ToLower(args[1])
+        f.push(formatFrame{name: style.Color, color: true, style: style})
+        return true, nil
+    case name == "gradient":
+        stops := make([]uint32, 0, len(args) - 1)
+        for _, arg := range args[1:] {
+            rgb, err := ParseColor(strings.ToLower(arg))
+            if err != nil {
+                return false, fmt.Errorf("Tag <%s>: %v", tag, err)
+            }
+            stops = append(stops, rgb)
+        }
+        switch len(stops) {
+        case 0:
+            stops = []uint32{0xffffff, 0x000000}
+        case 1:
+            return false, fmt.Errorf("Tag <%s> needs two colors or more", tag)
+        }
+        f.push(formatFrame{name: name, style: style, gradient: stops})
+        return true, nil
+    case formatNames[strings.TrimPrefix(name, "!")] != "":
+        on := !strings.HasPrefix(name, "!")
+        name = formatNames[strings.TrimPrefix(name, "!")]
+        setFormat(&style, name, Bool(on))
+        f.push(formatFrame{name: name, style: style})
+        return true, nil
+    case len(args) == 1:
+        if _, err := ParseColor(name); err == nil {
+            style.Color = name
+            f.push(formatFrame{name: name, color: true, style: style})
+            return true, nil
+        }
+    }
+
+    return false, nil
+
+}
+
+// applyGradient splits the segments into runes colored along the stops
+func applyGradient(segments []Chat, stops []uint32) []Chat {
+
+    total := 0
+    for _, seg := range segments {
+        total += len([]rune(seg.Text))
+    }
+
+    out := make([]Chat, 0, total)
+    i := 0
+    for _, seg := range segments {
+        for _, r := range seg.Text {
+            each := seg
+            each.Text = string(r)
+            each.Color = gradientColor(stops, i, total)
+            out = append(out, each)
+            i++
+        }
+    }
+
+    return out
+
+}
+
+func gradientColor(stops []uint32, i, total int) string {
+
+    pos := 0.0
+    if total > 1 {
+        pos = float64(i) / float64(total - 1) * float64(len(stops) - 1)
+    }
+    k := int(pos)
+    if k >= len(stops) - 1 {
+        k = len(stops) - 2
+    }
+    t := pos - float64(k)
+
+    rgb := uint32(0)
+    for _, shift := range []uint{16, 8, 0} {
+        a := float64(stops[k] >> shift & 0xff)
+        b := float64(stops[k + 1] >> shift & 0xff)
+        rgb |= uint32(a + (b - a) * t + 0.5) << shift
+    }
+
+    return fmt.Sprintf("#%06x", rgb)
+
+}
+
+// Legacy down-converts the chat to a string of § codes, for clients
+// before 1.16, hex colors becoming the nearest named ones
+func(c Chat) Legacy() string {
+    var b strings.Builder
+    var last legacyStyle
+    c.legacy(&b, Chat{}, &last)
+    return b.String()
+}
+
+type legacyStyle struct {
+    color byte
+    formats [5]bool // k l m n o
+}
+
+func(c Chat) legacy(b *strings.Builder, parent Chat, last *legacyStyle) {
+
+    // Inherited style
+    style := parent
+    if c.Color != "" {
+        style.Color = c.Color
+    }
+    for _, each := range []struct{ dst **bool; src *bool }{
+        {&style.Obfuscated, c.Obfuscated},
+        {&style.Bold, c.Bold},
+        {&style.Strikethrough, c.Strikethrough},
+        {&style.Underlined, c.Underlined},
+        {&style.Italic, c.Italic},
+    } {
+        if each.src != nil {
+            *each.dst = each.src
+        }
+    }
+
+    if text := c.content(); text != "" {
+        var want legacyStyle
+        color := style.Color
+        if strings.HasPrefix(color, "#") {
+            color = NearestColor(color)
+        }
+        for _, each := range ChatColors {
+            if each.Name == color {
+                want.color = each.Code
+            }
+        }
+        for i, on := range []*bool{style.Obfuscated, style.Bold, style.Strikethrough, style.Underlined, style.Italic} {
+            want.formats[i] = on != nil && *on
+        }
+
+        if want != *last {
+            turnedOff := false
+            for i := range want.formats {
+                turnedOff = turnedOff || (last.formats[i] && !want.formats[i])
+            }
+            from := *last
+            if want.color != last.color || turnedOff {
+                if want.color != 0 {
+                    b.WriteString("§" + string(want.color))
+                } else {
+                    b.WriteString("§r")
+                }
+                from = legacyStyle{color: want.color}
+            }
+            for i, on := range want.formats {
+                if on && !from.formats[i] {
+                    b.WriteString("§" + string("klmno"[i]))
+                }
+            }
+            *last = want
+        }
+        b.WriteString(text)
+    }
+
+    for _, sib := range c.Extra {
+        sib.legacy(b, style, last)
+    }
+
+}
@@ -0,0 +1,63 @@
+package packet
+
+import (
+    "testing"
+)
+
+func TestParseFormat(t *testing.T) {
+
+    samples := map[string] string{
+        "plain": `{"text":"","extra":[{"text":"plain"}]}`,
+        "&6Server &7is &csleeping": `{"text":"","extra":[{"text":"Server ","color":"gold"},{"text":"is ","color":"gray"},{"text":"sleeping","color":"red"}]}`,
+        "§lBold§r plain": `{"text":"","extra":[{"text":"Bold","bold":true},{"text":" plain"}]}`,
+        "&#ff8800hex & more": `{"text":"","extra":[{"text":"hex \u0026 more","color":"#ff8800"}]}`,
+        "<gold>a<bold>b</bold>c</gold>d": `{"text":"","extra":[{"text":"a","color":"gold"},{"text":"b","color":"gold","bold":true},{"text":"c","color":"gold"},{"text":"d"}]}`,
+        "<#ff8800>x</><!i>y": `{"text":"","extra":[{"text":"x","color":"#ff8800"},{"text":"y","italic":false}]}`,
+        "<color:red>x</color>": `{"text":"","extra":[{"text":"x","color":"red"}]}`,
+        "<gradient:#000000:#ffffff>abc</gradient>": `{"text":"","extra":[{"text":"a","color":"#000000"},{"text":"b","color":"#808080"},{"text":"c","color":"#ffffff"}]}`,
+        "a<newline>b \\<gold> <unknown>": `{"text":"","extra":[{"text":"a\nb \u003cgold\u003e \u003cunknown\u003e"}]}`,
+    }
+
+    for in, expected := range samples {
+        c, err := ParseFormat(in)
+        if err != nil {
+            t.Errorf("%s) %v", in, err)
+            continue
+        }
+        out := string(c.Bytes())
+        if out != expected {
+            t.Errorf("%s)\n%s !=\n%s", in, out, expected)
+        }
+    }
+
+    for _, in := range []string{"<gradient:red>x", "<color:nope>x", "<gradient:red:nope>"} {
+        _, err := ParseFormat(in)
+        t.Logf("%s) %v", in, err)
+        if err == nil {
+            t.Errorf("%s) no error", in)
+        }
+    }
+
+}
+
+func TestChatLegacy(t *testing.T) {
+
+    samples := map[string] string{
+        "&6Server &7is &csleeping": "§6Server §7is §csleeping",
+        "&l&6A&lB&r C": "§6A§lB§r C",
+        "<gold><bold>a</bold>b": "§6§la§6b",
+        "<#fe5050>hex": "§chex",
+    }
+
+    for in, expected := range samples {
+        c, err := ParseFormat(in)
+        if err != nil {
+            t.Fatal(err)
+        }
+        out := c.Legacy()
+        if out != expected {
+            t.Errorf("%s) %q != %q", in, out, expected)
+        }
+    }
+
+}
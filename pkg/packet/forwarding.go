@@ -0,0 +1,124 @@
+package packet
+
+import (
+    "bytes"
+    "crypto/hmac"
+    "crypto/sha256"
+    "encoding/hex"
+    "encoding/json"
+    "net"
+    "strings"
+
+    "github.com/hjjg200/act"
+)
+
+const (
+    IDLoginPluginResponse = 0x02
+    VelocityChannel = "velocity:player_info"
+    velocityVersion = 1 // MODERN_DEFAULT
+)
+
+// PlayerInfo
+// What the backend needs to know about a player behind the forwarder
+type PlayerInfo struct {
+    Address string // ip of the client
+    UUID [16]byte
+    Name string
+}
+
+func NewPlayerInfo(src net.Conn, start LoginStart) PlayerInfo {
+    host, _, err := net.SplitHostPort(src.RemoteAddr().String())
+    if err != nil {
+        host = src.RemoteAddr().String()
+    }
+    return PlayerInfo{
+        Address: host,
+        UUID: OfflineUUID(start.Name),
+        Name: start.Name,
+    }
+}
+
+// ForwardLegacy forwards a login with the player info put in the handshake
+// address the way BungeeCord does. Backends expect the info right after the
+// hostname, so mod loader markers go in the extraData property instead,
+// separated by \x01 rather than NUL.
+func ForwardLegacy(src net.Conn, hs Handshake, start LoginStart, dst net.Conn) {
+
+    info := NewPlayerInfo(src, start)
+    addr := ParseAddress(hs.Address)
+
+    properties := []legacyProperty{}
+    if addr.Modded() {
+        extra := "\x01" + strings.Join(addr.Markers, "\x01") + "\x01"
+        properties = append(properties, legacyProperty{Name: "extraData", Value: extra})
+    }
+    data, _ := json.Marshal(properties)
+
+    hs.Address = addr.Hostname + "\x00" + info.Address + "\x00" + hex.EncodeToString(info.UUID[:]) + "\x00" + string(data)
+
+    ForwardLogin(src, hs, start, dst)
+
+}
+
+// legacyProperty is a profile property of BungeeCord player info
+type legacyProperty struct {
+    Name string `json:"name"`
+    Value string `json:"value"`
+    Signature string `json:"signature"`
+}
+
+// ForwardModern forwards a login and answers the player info request of a
+// backend using Velocity modern forwarding, 1.13+
+func ForwardModern(src net.Conn, hs Handshake, start LoginStart, dst net.Conn, secret []byte) (err error) {
+
+    defer act.CatchAndStore(&err)
+
+    dst.Write(hs.Bytes())
+    dst.Write(start.Bytes())
+
+    // The request comes before compression is set
+    raw, err := ReadRawPacket(dst)
+    act.Try(err)
+    id, pr, err := ReadAnyPacket(bytes.NewReader(raw))
+    act.Try(err)
+    if id == IDLoginPluginRequest {
+        msgid := pr.NextVarInt()
+        if pr.NextString() == VelocityChannel {
+            payload := NewPlayerInfo(src, start).velocity()
+
+            mac := hmac.New(sha256.New, secret)
+            mac.Write(payload)
+
+            pk := NewPacket(IDLoginPluginResponse)
+            pk.PutVarInt(msgid)
+            pk.PutBool(true)
+            pk.put(mac.Sum(nil))
+            pk.put(payload)
+            dst.Write(pk.Bytes())
+
+            raw = nil
+        }
+    }
+    if raw != nil {
+        src.Write(raw)
+    }
+
+    pipe(src, dst)
+
+    return nil
+
+}
+
+func(info PlayerInfo) velocity() []byte {
+
+    pk := NewPacket(0)
+
+    pk.PutVarInt(velocityVersion)
+    pk.PutString(info.Address)
+    pk.PutUUID(info.UUID)
+    pk.PutString(info.Name)
+    pk.PutVarInt(0) // properties
+
+    return pk.data
+
+}
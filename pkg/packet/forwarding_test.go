@@ -0,0 +1,120 @@
+package packet
+
+import (
+    "bytes"
+    "crypto/sha256"
+    "encoding/hex"
+    "net"
+    "testing"
+)
+
+// Player info of Steve behind a net.Pipe, whose address is "pipe"
+const (
+    steveUUID = "5627dd98e6be3c21b8a8e92344183641"
+    stevePayload = "010470697065" + steveUUID + "05537465766500"
+    // HMAC-SHA256 of the payload with the key "secret"
+    steveSignature = "b7a15e1964adcf5674a8a45b2caf574fbce49b6d2c985660ec75b3b2be4ad4ed"
+)
+
+func TestForwardLegacy(t *testing.T) {
+
+    samples := []struct {
+        address, expected string
+    }{
+        {"mc.example.com", "mc.example.com\x00pipe\x00" + steveUUID + "\x00[]"},
+        // Markers are carried in extraData
+        {"mc.example.com\x00FML3\x00", "mc.example.com\x00pipe\x00" + steveUUID + "\x00" +
+            `[{"name":"extraData","value":"\u0001FML3\u0001","signature":""}]`},
+    }
+
+    for _, sample := range samples {
+        client, src := net.Pipe()
+        dst, backend := net.Pipe()
+
+        hs := Handshake{Protocol: 767, Address: sample.address, Port: 25565, NextState: StateLogin}
+        start := LoginStart{Name: "Steve"}
+
+        go ForwardLegacy(src, hs, start, dst)
+
+        hs1, err := ReadHandshake(backend)
+        if err != nil {
+            t.Fatal(err)
+        }
+        if hs1.Address != sample.expected || hs1.Protocol != hs.Protocol || hs1.Port != hs.Port {
+            t.Errorf("%q != %q", hs1.Address, sample.expected)
+        }
+        start1, err := ReadLoginStart(backend)
+        if err != nil || start1.Name != start.Name {
+            t.Errorf("%+v != %+v, %v", start1, start, err)
+        }
+
+        client.Close()
+        backend.Close()
+    }
+
+}
+
+func TestForwardModern(t *testing.T) {
+
+    secret := []byte("secret")
+    client, src := net.Pipe()
+    dst, backend := net.Pipe()
+    defer client.Close()
+    defer backend.Close()
+
+    hs := Handshake{Protocol: 767, Address: "localhost", Port: 25565, NextState: StateLogin}
+    start := LoginStart{Name: "Steve"}
+
+    go ForwardModern(src, hs, start, dst, secret)
+
+    hs1, err := ReadHandshake(backend)
+    if err != nil || hs1 != hs {
+        t.Fatalf("%+v != %+v, %v", hs1, hs, err)
+    }
+    start1, err := ReadLoginStart(backend)
+    if err != nil || start1.Name != start.Name {
+        t.Fatalf("%+v != %+v, %v", start1, start, err)
+    }
+
+    // Player info request
+    pk := NewPacket(IDLoginPluginRequest)
+    pk.PutVarInt(7)
+    pk.PutString(VelocityChannel)
+    backend.Write(pk.Bytes())
+
+    pr, err := NewPacketReader(IDLoginPluginResponse, backend)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if pr.NextVarInt() != 7 {
+        t.Fatal("Wrong message id")
+    }
+    if !pr.NextBool() {
+        t.Fatal("Not understood")
+    }
+    data := pr.Rest()
+    sig, payload := data[:sha256.Size], data[sha256.Size:]
+
+    if hex.EncodeToString(sig) != steveSignature {
+        t.Errorf("Wrong signature %x", sig)
+    }
+    if hex.EncodeToString(payload) != stevePayload {
+        t.Errorf("Wrong payload %x", payload)
+    }
+
+    pr = &PacketReader{r: bytes.NewReader(payload)}
+    version, addr, uuid, name, props := pr.NextVarInt(), pr.NextString(), pr.NextUUID(), pr.NextString(), pr.NextVarInt()
+    if version != velocityVersion || addr != "pipe" || uuid != OfflineUUID("Steve") ||
+        name != "Steve" || props != 0 || pr.Err() != nil {
+        t.Errorf("%d %s %x %s %d %v", version, addr, uuid, name, props, pr.Err())
+    }
+
+    // Raw piping afterwards
+    go backend.Write([]byte{0x01, 0x02})
+    p := make([]byte, 2)
+    client.Read(p)
+    if !bytes.Equal(p, []byte{0x01, 0x02}) {
+        t.Errorf("%x", p)
+    }
+
+}
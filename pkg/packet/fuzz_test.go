@@ -0,0 +1,142 @@
+package packet
+
+import (
+    "bufio"
+    "bytes"
+    "testing"
+)
+
+// Fuzz tests
+// Readers must return errors rather than panic on whatever clients send
+func fuzzSeeds(f *testing.F, seeds ...[]byte) {
+    for _, seed := range seeds {
+        f.Add(seed)
+    }
+    f.Add([]byte{})
+    f.Add([]byte{0x00})
+    f.Add([]byte{0xff, 0xff, 0xff, 0xff, 0x0f})
+}
+
+func FuzzReadVarInt(f *testing.F) {
+    fuzzSeeds(f, VarInt(-1), VarInt(300))
+    f.Fuzz(func(t *testing.T, p []byte) {
+        x, err := ReadVarInt(bytes.NewReader(p))
+        if err == nil && len(VarInt(x)) > 5 {
+            t.Errorf("%x", p)
+        }
+    })
+}
+
+func FuzzReadVarLong(f *testing.F) {
+    fuzzSeeds(f, VarLong(-1), VarLong(1 << 40))
+    f.Fuzz(func(t *testing.T, p []byte) {
+        ReadVarLong(bytes.NewReader(p))
+    })
+}
+
+func FuzzReadAnyPacket(f *testing.F) {
+    fuzzSeeds(f, Request{}.Bytes(), PingPong{1}.Bytes())
+    f.Fuzz(func(t *testing.T, p []byte) {
+        id, pr, err := ReadAnyPacket(bytes.NewReader(p))
+        if err == nil {
+            pr.NextString()
+            pr.NextInt(8)
+            pr.Rest()
+            t.Log(id, pr.Err())
+        }
+        ReadRawPacket(bytes.NewReader(p))
+    })
+}
+
+func FuzzReadHandshake(f *testing.F) {
+    fuzzSeeds(f, Handshake{767, "mc.example.com", 25565, StateLogin, false}.Bytes())
+    f.Fuzz(func(t *testing.T, p []byte) {
+        hs, err := ReadHandshake(bytes.NewReader(p))
+        if err != nil {
+            return
+        }
+        hs1, err := ReadHandshake(bytes.NewReader(hs.Bytes()))
+        if err != nil || hs1 != hs {
+            t.Errorf("%+v != %+v, %v", hs1, hs, err)
+        }
+    })
+}
+
+func FuzzReadRequest(f *testing.F) {
+    fuzzSeeds(f, Request{}.Bytes())
+    f.Fuzz(func(t *testing.T, p []byte) {
+        ReadRequest(bytes.NewReader(p))
+    })
+}
+
+func FuzzReadResponse(f *testing.F) {
+    fuzzSeeds(f, Response{Description: Chat{Text: "motd"}}.Bytes())
+    f.Fuzz(func(t *testing.T, p []byte) {
+        ReadResponse(bytes.NewReader(p))
+    })
+}
+
+func FuzzReadPingPong(f *testing.F) {
+    fuzzSeeds(f, PingPong{42}.Bytes())
+    f.Fuzz(func(t *testing.T, p []byte) {
+        pp, err := ReadPingPong(bytes.NewReader(p))
+        if err != nil {
+            return
+        }
+        pp1, err := ReadPingPong(bytes.NewReader(pp.Bytes()))
+        if err != nil || pp1 != pp {
+            t.Errorf("%+v != %+v, %v", pp1, pp, err)
+        }
+    })
+}
+
+func FuzzReadLoginStart(f *testing.F) {
+    fuzzSeeds(f, LoginStart{Name: "Steve", rest: make([]byte, 16)}.Bytes())
+    f.Fuzz(func(t *testing.T, p []byte) {
+        start, err := ReadLoginStart(bytes.NewReader(p))
+        if err != nil {
+            return
+        }
+        start1, err := ReadLoginStart(bytes.NewReader(start.Bytes()))
+        if err != nil || start1.Name != start.Name || !bytes.Equal(start1.rest, start.rest) {
+            t.Errorf("%+v != %+v, %v", start1, start, err)
+        }
+    })
+}
+
+func FuzzReadDisconnect(f *testing.F) {
+    fuzzSeeds(f, Disconnect{Chat{Text: "bye"}}.Bytes())
+    f.Fuzz(func(t *testing.T, p []byte) {
+        ReadDisconnect(bytes.NewReader(p))
+    })
+}
+
+func FuzzReadProxyHeader(f *testing.F) {
+    fuzzSeeds(f, []byte("PROXY TCP4 1.2.3.4 5.6.7.8 1000 25565\r\n"), proxyV2Sig)
+    f.Fuzz(func(t *testing.T, p []byte) {
+        readProxyHeaderV1(bufio.NewReader(bytes.NewReader(p)))
+        readProxyHeaderV2(bufio.NewReader(bytes.NewReader(p)))
+    })
+}
+
+func FuzzReadLegacyString(f *testing.F) {
+    fuzzSeeds(f, []byte{0x00, 0x02, 0x00, 'M', 0x00, 'C'})
+    f.Fuzz(func(t *testing.T, p []byte) {
+        readLegacyString(bytes.NewReader(p), 255)
+    })
+}
+
+func FuzzReadNBT(f *testing.F) {
+    fuzzSeeds(f, Chat{Text: "nbt", Extra: []Chat{{Text: "x"}}}.NBT())
+    f.Fuzz(func(t *testing.T, p []byte) {
+        v, err := ReadNBT(bytes.NewReader(p))
+        if err != nil {
+            return
+        }
+        p1 := AppendNBT(nil, v)
+        v1, err := ReadNBT(bytes.NewReader(p1))
+        if err != nil || !bytes.Equal(AppendNBT(nil, v1), p1) {
+            t.Errorf("%#v != %#v, %v", v1, v, err)
+        }
+    })
+}
@@ -1,6 +1,7 @@
 package packet
 
 import (
+    "errors"
     "fmt"
     "io"
     "net"
@@ -21,6 +22,25 @@ func(hf HandlerFunc) Serve(conn net.Conn, hs Handshake) {
 
 func Forward(src net.Conn, hs Handshake, dst net.Conn) {
 
+    // Legacy pings are still unread
+    if !hs.Legacy {
+        dst.Write(hs.Bytes())
+    }
+    pipe(src, dst)
+
+}
+
+// ForwardLogin forwards a login whose login start was already read
+func ForwardLogin(src net.Conn, hs Handshake, start LoginStart, dst net.Conn) {
+
+    dst.Write(hs.Bytes())
+    dst.Write(start.Bytes())
+    pipe(src, dst)
+
+}
+
+func pipe(src, dst net.Conn) {
+
     var wg sync.WaitGroup
     wg.Add(2)
 
@@ -31,8 +51,6 @@ func Forward(src net.Conn, hs Handshake, dst net.Conn) {
         wg.Done()
     }
 
-    dst.Write(hs.Bytes())
-
     go conncopy(src, dst)
     go conncopy(dst, src)
     wg.Wait()
@@ -57,6 +75,12 @@ func ServeDisconnect(src net.Conn, hs Handshake, reason Chat) {
 
 func ServeResponse(src net.Conn, hs Handshake, rsp Response) {
 
+    if hs.Legacy {
+        src.Write(LegacyKick(LegacyResponse(hs.Protocol, rsp)))
+        src.Close()
+        return
+    }
+
     if hs.NextState != StateStatus {
         src.Close()
         return
@@ -76,21 +100,58 @@ func ServeResponse(src net.Conn, hs Handshake, rsp Response) {
 }
 
 func ListenAndServe(addr string, handler Handler) error {
+    return ListenAndServeProxy(addr, nil, handler)
+}
+
+// ListenAndServeProxy accepts PROXY headers from the given networks so that
+// handlers see the real client address
+func ListenAndServeProxy(addr string, trustedNets []*net.IPNet, handler Handler) error {
 
     ln, err := net.Listen("tcp", addr)
     if err != nil {
         return err
     }
 
+    return Serve(ln, trustedNets, handler)
+
+}
+
+// Serve serves the listener until it is closed
+func Serve(ln net.Listener, trustedNets []*net.IPNet, handler Handler) error {
+
     for {
 
         conn, err := ln.Accept()
-        if err != nil {
+        if errors.Is(err, net.ErrClosed) {
+            return fmt.Errorf("Server stopped")
+        } else if err != nil {
             fmt.Println("Connection exception:", err)
+            continue
         }
 
         go func(src net.Conn) {
 
+            if trusted(src.RemoteAddr(), trustedNets) {
+                pc, err := AcceptProxyHeader(src)
+                if err != nil {
+                    fmt.Println("PROXY header exception:", err)
+                    src.Close()
+                    return
+                }
+                src = pc
+            }
+
+            lc, legacy, err := AcceptLegacyPing(src)
+            if err != nil {
+                src.Close()
+                return
+            }
+            src = lc
+            if legacy != nil {
+                handler.Serve(src, *legacy)
+                return
+            }
+
             hs, err := ReadHandshake(src)
             if err != nil {
                 return
@@ -102,7 +163,5 @@ func ListenAndServe(addr string, handler Handler) error {
 
     }
 
-    return fmt.Errorf("Server stopped")
-
 }
 
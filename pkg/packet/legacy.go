@@ -0,0 +1,216 @@
+package packet
+
+import (
+    "bufio"
+    "bytes"
+    "encoding/binary"
+    "errors"
+    "fmt"
+    "io"
+    "net"
+    "os"
+    "strconv"
+    "strings"
+    "time"
+    "unicode/utf16"
+)
+
+// Legacy ping
+// Server list pings of clients before 1.7, which have no handshake:
+//   beta - 1.3  FE
+//   1.4 - 1.5   FE 01, or FE 02 from some clients
+//   1.6         FE 01 FA, then a MC|PingHost plugin message
+// They are given to handlers as a status Handshake marked Legacy, with
+// nothing read from the connection so that it can be forwarded as is
+const (
+    IDLegacyPing = 0xfe
+    IDLegacyKick = 0xff
+    LegacyProtocolBeta = -1 // protocol of beta pings, which get the oldest reply
+    LegacyProtocolUnknown = 0 // protocol of 1.4 and 1.5 pings
+    legacyPingHost = "MC|PingHost"
+    legacyWait = 500 * time.Millisecond
+)
+
+var errNotLegacy = errors.New("Not a legacy ping")
+
+// legacyConn
+// Connection whose first bytes were peeked or replayed
+type legacyConn struct {
+    net.Conn
+    r io.Reader
+}
+
+func(lc *legacyConn) Read(p []byte) (int, error) {
+    return lc.r.Read(p)
+}
+
+// AcceptLegacyPing checks whether the connection starts with a legacy
+// ping. The returned connection is to be used in place of the given one
+// either way, and the handshake is nil unless it is a legacy ping.
+func AcceptLegacyPing(conn net.Conn) (net.Conn, *Handshake, error) {
+
+    br := bufio.NewReader(conn)
+    b, err := br.Peek(1)
+    if err != nil {
+        return nil, nil, err
+    }
+    if b[0] != IDLegacyPing {
+        return &legacyConn{conn, br}, nil, nil
+    }
+
+    var raw bytes.Buffer
+    hs, err := readLegacyPing(conn, io.TeeReader(br, &raw))
+    replay := &legacyConn{conn, io.MultiReader(bytes.NewReader(raw.Bytes()), br)}
+    if err == errNotLegacy {
+        return replay, nil, nil
+    } else if err != nil {
+        return nil, nil, err
+    }
+
+    return replay, &hs, nil
+
+}
+
+func readLegacyPing(conn net.Conn, r io.Reader) (hs Handshake, err error) {
+
+    hs.NextState = StateStatus
+    hs.Legacy = true
+    hs.Protocol = LegacyProtocolBeta
+
+    p := make([]byte, 1)
+    _, err = io.ReadFull(r, p)
+    if err != nil {
+        return
+    }
+
+    // Older clients stop early, which is only told by waiting
+    conn.SetReadDeadline(time.Now().Add(legacyWait))
+    defer conn.SetReadDeadline(time.Time{})
+    timedOut := func(err error) bool {
+        return errors.Is(err, os.ErrDeadlineExceeded)
+    }
+
+    _, err = io.ReadFull(r, p)
+    if timedOut(err) {
+        return hs, nil
+    } else if err != nil {
+        return
+    } else if p[0] != 0x01 && p[0] != 0x02 {
+        // Longer handshakes begin with FE too, e.g. FE 03 for 510 bytes
+        return hs, errNotLegacy
+    }
+    hs.Protocol = LegacyProtocolUnknown
+
+    _, err = io.ReadFull(r, p)
+    if timedOut(err) {
+        return hs, nil
+    } else if err != nil {
+        return
+    } else if p[0] != 0xfa {
+        // FE 01 and FE 02 are also how handshakes of 254 and 382 bytes begin
+        return hs, errNotLegacy
+    }
+
+    // MC|PingHost
+    channel, err := readLegacyString(r, 32)
+    if err != nil {
+        return
+    } else if channel != legacyPingHost {
+        return hs, fmt.Errorf("Unknown legacy ping channel %s", channel)
+    }
+
+    var head struct {
+        Length uint16
+        Protocol uint8
+    }
+    err = binary.Read(r, binary.BigEndian, &head)
+    if err != nil {
+        return
+    }
+    hs.Protocol = int32(head.Protocol)
+
+    hs.Address, err = readLegacyString(r, 255)
+    if err != nil {
+        return
+    }
+
+    var port int32
+    err = binary.Read(r, binary.BigEndian, &port)
+    hs.Port = uint16(port)
+
+    return
+
+}
+
+// readLegacyString reads a string of UTF-16 units prefixed by their count
+func readLegacyString(r io.Reader, max int) (string, error) {
+
+    var n uint16
+    err := binary.Read(r, binary.BigEndian, &n)
+    if err != nil {
+        return "", err
+    }
+    if int(n) > max {
+        return "", fmt.Errorf("Legacy string of %d is too long", n)
+    }
+
+    units := make([]uint16, n)
+    err = binary.Read(r, binary.BigEndian, units)
+    if err != nil {
+        return "", err
+    }
+
+    return string(utf16.Decode(units)), nil
+
+}
+
+// LegacyKick is the packet legacy pings are answered with
+func LegacyKick(reason string) []byte {
+
+    units := utf16.Encode([]rune(reason))
+
+    p := []byte{IDLegacyKick}
+    p = binary.BigEndian.AppendUint16(p, uint16(len(units)))
+    for _, unit := range units {
+        p = binary.BigEndian.AppendUint16(p, unit)
+    }
+
+    return p
+
+}
+
+// LegacyResponse formats the response for the protocol of a legacy ping
+func LegacyResponse(protocol int32, rsp Response) string {
+
+    motd := strings.ReplaceAll(rsp.Description.Legacy(), "\n", " ")
+    online := strconv.Itoa(rsp.Players.Online)
+    max := strconv.Itoa(rsp.Players.Max)
+
+    if protocol == LegacyProtocolBeta {
+        // § separates the fields, so there can be no codes
+        return stripLegacyCodes(motd) + "§" + online + "§" + max
+    }
+
+    return strings.Join([]string{
+        "§1",
+        strconv.Itoa(rsp.Version.Protocol),
+        rsp.Version.Name,
+        motd,
+        online,
+        max,
+    }, "\x00")
+
+}
+
+func stripLegacyCodes(s string) string {
+    var b strings.Builder
+    rs := []rune(s)
+    for i := 0; i < len(rs); i++ {
+        if rs[i] == '§' {
+            i++
+            continue
+        }
+        b.WriteRune(rs[i])
+    }
+    return b.String()
+}
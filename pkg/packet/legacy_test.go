@@ -0,0 +1,98 @@
+package packet
+
+import (
+    "bytes"
+    "encoding/binary"
+    "io"
+    "net"
+    "testing"
+    "unicode/utf16"
+)
+
+func legacyString(s string) []byte {
+    units := utf16.Encode([]rune(s))
+    p := binary.BigEndian.AppendUint16(nil, uint16(len(units)))
+    for _, unit := range units {
+        p = binary.BigEndian.AppendUint16(p, unit)
+    }
+    return p
+}
+
+func TestAcceptLegacyPing(t *testing.T) {
+
+    // 1.6
+    ping16 := []byte{0xfe, 0x01, 0xfa}
+    ping16 = append(ping16, legacyString("MC|PingHost")...)
+    host := legacyString("mc.example.com")
+    ping16 = binary.BigEndian.AppendUint16(ping16, uint16(1 + len(host) + 4))
+    ping16 = append(ping16, 78)
+    ping16 = append(ping16, host...)
+    ping16 = binary.BigEndian.AppendUint32(ping16, 25565)
+
+    samples := []struct {
+        in []byte
+        expected *Handshake
+    }{
+        {ping16, &Handshake{78, "mc.example.com", 25565, StateStatus, true}},
+        {[]byte{0xfe, 0x01}, &Handshake{LegacyProtocolUnknown, "", 0, StateStatus, true}},
+        {[]byte{0xfe, 0x02}, &Handshake{LegacyProtocolUnknown, "", 0, StateStatus, true}},
+        {[]byte{0xfe}, &Handshake{LegacyProtocolBeta, "", 0, StateStatus, true}},
+        {Handshake{767, "mc.example.com", 25565, StateStatus, false}.Bytes(), nil},
+        // Handshakes of 254 and 382 bytes, which begin with FE 01 and FE 02
+        {Handshake{767, string(bytes.Repeat([]byte("a"), 246)), 25565, StateLogin, false}.Bytes(), nil},
+        {Handshake{767, string(bytes.Repeat([]byte("a"), 374)), 25565, StateLogin, false}.Bytes(), nil},
+        // Longer handshakes, FE 03 and FE 04
+        {Handshake{767, string(bytes.Repeat([]byte("a"), 502)), 25565, StateLogin, false}.Bytes(), nil},
+        {Handshake{767, string(bytes.Repeat([]byte("a"), 630)), 25565, StateLogin, false}.Bytes(), nil},
+    }
+
+    for i, sample := range samples {
+        client, server := net.Pipe()
+        go client.Write(sample.in)
+
+        conn, hs, err := AcceptLegacyPing(server)
+        if err != nil {
+            t.Fatalf("%d) %v", i, err)
+        }
+        if (hs == nil) != (sample.expected == nil) || (hs != nil && *hs != *sample.expected) {
+            t.Errorf("%d) %+v != %+v", i, hs, sample.expected)
+        }
+
+        // Nothing is consumed
+        p := make([]byte, len(sample.in))
+        _, err = io.ReadFull(conn, p)
+        if err != nil || !bytes.Equal(p, sample.in) {
+            t.Errorf("%d) replayed %x, %v", i, p, err)
+        }
+
+        client.Close()
+        server.Close()
+    }
+
+}
+
+func TestLegacyResponse(t *testing.T) {
+
+    rsp := Response{
+        Version: VersionStruct{"1.6.4", 78},
+        Players: PlayersStruct{Max: 20, Online: 3},
+        Description: Chat{Text: "A", Color: "gold", Extra: []Chat{{Text: "\nB"}}},
+    }
+
+    samples := map[int32] string{
+        78: "§1\x0078\x001.6.4\x00§6A B\x003\x0020",
+        LegacyProtocolBeta: "A B§3§20",
+    }
+    for protocol, expected := range samples {
+        out := LegacyResponse(protocol, rsp)
+        if out != expected {
+            t.Errorf("%d) %q != %q", protocol, out, expected)
+        }
+    }
+
+    kick := LegacyKick("§1")
+    if !bytes.Equal(kick, []byte{0xff, 0, 2, 0x00, 0xa7, 0, '1'}) {
+        t.Errorf("%x", kick)
+    }
+
+}
@@ -0,0 +1,283 @@
+package packet
+
+import (
+    "crypto/md5"
+    "fmt"
+    "io"
+    "net"
+    "time"
+
+    "github.com/hjjg200/act"
+)
+
+const (
+    IDLoginSuccess = 0x02
+    IDLoginAcknowledged = 0x03
+    IDLoginPluginRequest = 0x04
+    StateTransfer = 3 // handshake intent of transferred clients, 1.20.5+
+)
+
+// Limbo
+// Completes the login itself and holds the player until the backend is
+// ready. Clients of the versions in limboVersions are kept in an empty
+// world and transferred back to the forwarder; other 1.13+ clients are
+// held in the login state and asked to rejoin.
+type Limbo struct {
+    Ready func() (bool, error) // polled every tick
+    Timeout time.Duration
+    Rejoin Chat // shown to clients that cannot be transferred
+    Failed Chat // shown when the backend did not come up
+}
+
+const (
+    LimboTick = 5 * time.Second
+    minLimboProtocol = 393 // 1.13, login plugin requests
+    limboChannel = "minecraft-forwarder:limbo"
+)
+
+// limboTick is LimboTick, shortened by tests
+var limboTick = LimboTick
+
+type limboRegistry struct {
+    id string
+    entries []string
+}
+
+type limboVersion struct {
+    pack string // version of the minecraft:core known pack
+    strictErrors bool
+    registries []limboRegistry
+}
+
+// Damage types the client resolves as soon as it joins a world
+var limboDamageTypes = []string{
+    "minecraft:in_fire", "minecraft:lightning_bolt", "minecraft:on_fire",
+    "minecraft:lava", "minecraft:hot_floor", "minecraft:in_wall",
+    "minecraft:cramming", "minecraft:drown", "minecraft:starve",
+    "minecraft:cactus", "minecraft:fall", "minecraft:fly_into_wall",
+    "minecraft:out_of_world", "minecraft:generic", "minecraft:magic",
+    "minecraft:wither", "minecraft:dragon_breath", "minecraft:dry_out",
+    "minecraft:sweet_berry_bush", "minecraft:freeze", "minecraft:stalagmite",
+    "minecraft:outside_border", "minecraft:generic_kill",
+}
+
+var limboRegistries = []limboRegistry{
+    {"minecraft:dimension_type", []string{"minecraft:overworld"}},
+    {"minecraft:worldgen/biome", []string{"minecraft:plains"}},
+    {"minecraft:damage_type", limboDamageTypes},
+    {"minecraft:wolf_variant", []string{"minecraft:pale"}},
+}
+
+var limboVersions = map[int32] limboVersion{
+    766: { // 1.20.5, 1.20.6
+        pack: "1.20.5",
+        strictErrors: true,
+        registries: limboRegistries,
+    },
+    767: { // 1.21, 1.21.1
+        pack: "1.21",
+        strictErrors: true,
+        registries: append(limboRegistries, limboRegistry{
+            "minecraft:painting_variant", []string{"minecraft:kebab"},
+        }),
+    },
+}
+
+// CanLimbo reports whether the limbo can hold clients of the protocol
+func CanLimbo(protocol int32) bool {
+    return protocol >= minLimboProtocol
+}
+
+// OfflineUUID returns the uuid an offline mode server gives the player
+func OfflineUUID(name string) (uuid [16]byte) {
+    uuid = md5.Sum([]byte("OfflinePlayer:" + name))
+    uuid[6] = uuid[6] & 0x0f | 0x30
+    uuid[8] = uuid[8] & 0x3f | 0x80
+    return uuid
+}
+
+// Serve expects the handshake and the login start were read
+func(lb Limbo) Serve(src net.Conn, hs Handshake, start LoginStart) (err error) {
+
+    defer act.CatchAndStore(&err)
+    defer src.Close()
+
+    act.Assert(CanLimbo(hs.Protocol), fmt.Errorf("Protocol %d cannot be held", hs.Protocol))
+
+    v, ok := limboVersions[hs.Protocol]
+    if !ok {
+        return lb.serveLogin(src)
+    }
+    id := func(typ PacketType) int {
+        id, ok := PacketID(typ, hs.Protocol)
+        act.Assert(ok, fmt.Errorf("Packet %d has no ID in protocol %d", typ, hs.Protocol))
+        return id
+    }
+
+    // Login
+    pk := NewPacket(id(PacketLoginSuccess))
+    pk.PutUUID(OfflineUUID(start.Name))
+    pk.PutString(start.Name)
+    pk.PutVarInt(0) // properties
+    if v.strictErrors {
+        pk.PutBool(false)
+    }
+    src.Write(pk.Bytes())
+
+    _, err = NewPacketReader(id(PacketLoginAcknowledged), src)
+    act.Try(err)
+
+    // Configuration
+    pk = NewPacket(id(PacketConfigKnownPacks))
+    pk.PutVarInt(1)
+    pk.PutString("minecraft")
+    pk.PutString("core")
+    pk.PutString(v.pack)
+    src.Write(pk.Bytes())
+
+    act.Try(skipUntil(src, id(PacketConfigKnownPacksAck)))
+
+    for _, reg := range v.registries {
+        pk = NewPacket(id(PacketConfigRegistry))
+        pk.PutString(reg.id)
+        pk.PutVarInt(int32(len(reg.entries)))
+        for _, entry := range reg.entries {
+            pk.PutString(entry)
+            pk.PutBool(false) // data comes from the known pack
+        }
+        src.Write(pk.Bytes())
+    }
+
+    src.Write(NewPacket(id(PacketConfigFinish)).Bytes())
+    act.Try(skipUntil(src, id(PacketConfigFinishAck)))
+
+    // Play
+    pk = NewPacket(id(PacketPlayLogin))
+    pk.PutInt(1, 4) // entity id
+    pk.PutBool(false) // hardcore
+    pk.PutVarInt(1)
+    pk.PutString("minecraft:overworld")
+    pk.PutVarInt(1) // max players
+    pk.PutVarInt(2) // view distance
+    pk.PutVarInt(2) // simulation distance
+    pk.PutBool(false) // reduced debug info
+    pk.PutBool(false) // respawn screen
+    pk.PutBool(false) // limited crafting
+    pk.PutVarInt(0) // dimension type
+    pk.PutString("minecraft:overworld")
+    pk.PutInt(0, 8) // hashed seed
+    pk.PutInt(3, 1) // spectator
+    pk.PutInt(-1, 1) // previous game mode
+    pk.PutBool(false) // debug
+    pk.PutBool(true) // flat
+    pk.PutBool(false) // death location
+    pk.PutVarInt(0) // portal cooldown
+    pk.PutBool(false) // secure chat
+    src.Write(pk.Bytes())
+
+    pk = NewPacket(id(PacketPlayPosition))
+    pk.PutDouble(0)
+    pk.PutDouble(400)
+    pk.PutDouble(0)
+    pk.PutFloat(0)
+    pk.PutFloat(0)
+    pk.PutInt(0, 1) // absolute
+    pk.PutVarInt(1) // teleport id
+    src.Write(pk.Bytes())
+
+    pk = NewPacket(id(PacketPlayGameEvent))
+    pk.PutInt(13, 1) // start waiting for level chunks
+    pk.PutFloat(0)
+    src.Write(pk.Bytes())
+
+    keepAlive := func(n int64) {
+        pk := NewPacket(id(PacketPlayKeepAlive))
+        pk.PutInt(n, 8)
+        src.Write(pk.Bytes())
+    }
+    if !lb.hold(src, keepAlive) {
+        pk = NewPacket(id(PacketPlayDisconnect))
+        pk.put(lb.Failed.NBT())
+        src.Write(pk.Bytes())
+        return nil
+    }
+
+    pk = NewPacket(id(PacketPlayTransfer))
+    pk.PutString(hs.Hostname())
+    pk.PutVarInt(int32(hs.Port))
+    src.Write(pk.Bytes())
+
+    return nil
+
+}
+
+// serveLogin holds the client in the login state with plugin requests,
+// which it has to answer, so that the connection does not time out
+func(lb Limbo) serveLogin(src net.Conn) error {
+
+    keepAlive := func(n int64) {
+        pk := NewPacket(IDLoginPluginRequest)
+        pk.PutVarInt(int32(n))
+        pk.PutString(limboChannel)
+        src.Write(pk.Bytes())
+    }
+
+    reason := lb.Rejoin
+    if !lb.hold(src, keepAlive) {
+        reason = lb.Failed
+    }
+    src.Write(Disconnect{reason}.Bytes())
+
+    return nil
+
+}
+
+// hold keeps the connection alive until the backend is ready, the limbo
+// times out or the client leaves
+func(lb Limbo) hold(src net.Conn, keepAlive func(int64)) bool {
+
+    // Drain whatever the client sends
+    gone := make(chan struct{})
+    go func() {
+        defer close(gone)
+        for {
+            _, _, err := ReadAnyPacket(src)
+            if err != nil {
+                return
+            }
+        }
+    }()
+
+    ticker := time.NewTicker(limboTick)
+    defer ticker.Stop()
+    deadline := time.Now().Add(lb.Timeout)
+
+    for n := int64(1); ; n++ {
+        select {
+        case <-gone:
+            act.Try(io.EOF)
+        case <-ticker.C:
+        }
+
+        keepAlive(n)
+
+        ready, err := lb.Ready()
+        if err != nil || time.Now().After(deadline) {
+            return false
+        }
+        if ready {
+            return true
+        }
+    }
+
+}
+
+func skipUntil(rd io.Reader, id int) error {
+    dec := NewDecoder(rd)
+    for {
+        id1, _, err := dec.ReadPacket()
+        if err != nil || id1 == id {
+            return err
+        }
+    }
+}
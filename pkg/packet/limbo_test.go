@@ -0,0 +1,115 @@
+package packet
+
+import (
+    "net"
+    "testing"
+    "time"
+)
+
+// limboClient plays the client side of the limbo, answering what it has to,
+// and returns the ids of the packets it got until the connection closed
+func limboClient(conn net.Conn, protocol int32) ([]int, *PacketReader) {
+
+    answers := map[PacketType] PacketType{
+        PacketLoginSuccess: PacketLoginAcknowledged,
+        PacketConfigKnownPacks: PacketConfigKnownPacksAck,
+        PacketConfigFinish: PacketConfigFinishAck,
+    }
+    replies := make(map[int] int)
+    for typ, answer := range answers {
+        id, _ := PacketID(typ, protocol)
+        replies[id], _ = PacketID(answer, protocol)
+    }
+
+    var ids []int
+    var last *PacketReader
+    for {
+        id, pr, err := ReadAnyPacket(conn)
+        if err != nil {
+            return ids, last
+        }
+        ids = append(ids, id)
+        last = pr
+        if reply, ok := replies[id]; ok {
+            conn.Write(NewPacket(reply).Bytes())
+        }
+    }
+
+}
+
+func TestLimboServe(t *testing.T) {
+
+    defer func(tick time.Duration) {
+        limboTick = tick
+    }(limboTick)
+    limboTick = 10 * time.Millisecond
+
+    for protocol, v := range limboVersions {
+        id := func(typ PacketType) int {
+            id, ok := PacketID(typ, protocol)
+            if !ok {
+                t.Fatalf("Packet %d has no ID in protocol %d", typ, protocol)
+            }
+            return id
+        }
+        sequence := []int{id(PacketLoginSuccess), id(PacketConfigKnownPacks)}
+        for range v.registries {
+            sequence = append(sequence, id(PacketConfigRegistry))
+        }
+        sequence = append(sequence, id(PacketConfigFinish),
+            id(PacketPlayLogin), id(PacketPlayPosition), id(PacketPlayGameEvent))
+
+        hs := Handshake{Protocol: protocol, Address: "mc.example.com\x00FML3\x00", Port: 25566, NextState: StateLogin}
+        start := LoginStart{Name: "Steve"}
+
+        // Transferred once ready, after a keep-alive per tick
+        polls := 0
+        lb := Limbo{
+            Ready: func() (bool, error) {
+                polls++
+                return polls == 2, nil
+            },
+            Timeout: time.Minute,
+        }
+        client, server := net.Pipe()
+        go lb.Serve(server, hs, start)
+        ids, pr := limboClient(client, protocol)
+        expected := append(append([]int{}, sequence...),
+            id(PacketPlayKeepAlive), id(PacketPlayKeepAlive), id(PacketPlayTransfer))
+        if !equalInts(ids, expected) {
+            t.Errorf("%d) ready: %x != %x", protocol, ids, expected)
+        }
+        if host, port := pr.NextString(), pr.NextVarInt(); host != "mc.example.com" || port != 25566 {
+            t.Errorf("%d) transferred to %s:%d", protocol, host, port)
+        }
+
+        // Disconnected once timed out
+        lb = Limbo{
+            Ready: func() (bool, error) {
+                return false, nil
+            },
+            Failed: Chat{Text: "Failed"},
+        }
+        client, server = net.Pipe()
+        go lb.Serve(server, hs, start)
+        ids, _ = limboClient(client, protocol)
+        expected = append(append([]int{}, sequence...),
+            id(PacketPlayKeepAlive), id(PacketPlayDisconnect))
+        if !equalInts(ids, expected) {
+            t.Errorf("%d) timeout: %x != %x", protocol, ids, expected)
+        }
+    }
+
+}
+
+func equalInts(a, b []int) bool {
+    if len(a) != len(b) {
+        return false
+    }
+    for i := range a {
+        if a[i] != b[i] {
+            return false
+        }
+    }
+    return true
+}
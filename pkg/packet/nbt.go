@@ -0,0 +1,386 @@
+package packet
+
+import (
+    "bytes"
+    "encoding/binary"
+    "encoding/json"
+    "fmt"
+    "io"
+    "math"
+    "sort"
+    "unicode/utf16"
+)
+
+// NBT
+// Tags are Go values of these types:
+//   Byte int8, Short int16, Int int32, Long int64, Float float32,
+//   Double float64, Byte Array []byte, String string, List []interface{},
+//   Compound map[string] interface{}, Int Array []int32, Long Array []int64
+// For encoding, bool is a Byte, int an Int or a Long, and json.Number
+// whichever fits, so that values decoded from JSON can be used as they are.
+// On the network the root is nameless since 1.20.2.
+const (
+    tagEnd = 0x00
+    tagByte = 0x01
+    tagShort = 0x02
+    tagInt = 0x03
+    tagLong = 0x04
+    tagFloat = 0x05
+    tagDouble = 0x06
+    tagByteArray = 0x07
+    tagString = 0x08
+    tagList = 0x09
+    tagCompound = 0x0a
+    tagIntArray = 0x0b
+    tagLongArray = 0x0c
+    nbtMaxDepth = 512
+)
+
+func nbtType(v interface{}) byte {
+    switch v := v.(type) {
+    case bool, int8:
+        return tagByte
+    case int16:
+        return tagShort
+    case int32:
+        return tagInt
+    case int:
+        if v >= math.MinInt32 && v <= math.MaxInt32 {
+            return tagInt
+        }
+        return tagLong
+    case int64:
+        return tagLong
+    case float32:
+        return tagFloat
+    case float64:
+        return tagDouble
+    case json.Number:
+        if i, err := v.Int64(); err == nil {
+            if i >= math.MinInt32 && i <= math.MaxInt32 {
+                return tagInt
+            }
+            return tagLong
+        }
+        return tagDouble
+    case []byte:
+        return tagByteArray
+    case string:
+        return tagString
+    case []interface{}:
+        return tagList
+    case map[string] interface{}:
+        return tagCompound
+    case []int32:
+        return tagIntArray
+    case []int64:
+        return tagLongArray
+    }
+    return tagEnd
+}
+
+// AppendNBT appends a nameless tag, or an End tag if the value cannot be
+// encoded
+func AppendNBT(p []byte, v interface{}) []byte {
+    typ := nbtType(v)
+    p = append(p, typ)
+    if typ == tagEnd {
+        return p
+    }
+    return appendNBTPayload(p, v)
+}
+
+// appendNBTString writes modified UTF-8, in which NUL takes two bytes and
+// runes beyond the BMP are written as surrogate pairs
+func appendNBTString(p []byte, s string) []byte {
+
+    var m []byte
+    for _, r := range s {
+        switch {
+        case r == 0:
+            m = append(m, 0xc0, 0x80)
+        case r < 0x80:
+            m = append(m, byte(r))
+        case r < 0x800:
+            m = append(m, 0xc0 | byte(r >> 6), 0x80 | byte(r) & 0x3f)
+        case r < 0x10000:
+            m = append(m, 0xe0 | byte(r >> 12), 0x80 | byte(r >> 6) & 0x3f, 0x80 | byte(r) & 0x3f)
+        default:
+            r1, r2 := utf16.EncodeRune(r)
+            for _, u := range []rune{r1, r2} {
+                m = append(m, 0xe0 | byte(u >> 12), 0x80 | byte(u >> 6) & 0x3f, 0x80 | byte(u) & 0x3f)
+            }
+        }
+    }
+
+    p = binary.BigEndian.AppendUint16(p, uint16(len(m)))
+    return append(p, m...)
+
+}
+
+func appendNBTPayload(p []byte, v interface{}) []byte {
+
+    be := binary.BigEndian
+    switch v := v.(type) {
+    case bool:
+        if v {
+            return append(p, 1)
+        }
+        return append(p, 0)
+    case int8:
+        return append(p, byte(v))
+    case int16:
+        return be.AppendUint16(p, uint16(v))
+    case int32:
+        return be.AppendUint32(p, uint32(v))
+    case int:
+        if nbtType(v) == tagInt {
+            return be.AppendUint32(p, uint32(v))
+        }
+        return be.AppendUint64(p, uint64(v))
+    case int64:
+        return be.AppendUint64(p, uint64(v))
+    case float32:
+        return be.AppendUint32(p, math.Float32bits(v))
+    case float64:
+        return be.AppendUint64(p, math.Float64bits(v))
+    case json.Number:
+        switch nbtType(v) {
+        case tagInt:
+            i, _ := v.Int64()
+            return be.AppendUint32(p, uint32(i))
+        case tagLong:
+            i, _ := v.Int64()
+            return be.AppendUint64(p, uint64(i))
+        }
+        f, _ := v.Float64()
+        return be.AppendUint64(p, math.Float64bits(f))
+    case []byte:
+        p = be.AppendUint32(p, uint32(len(v)))
+        return append(p, v...)
+    case string:
+        return appendNBTString(p, v)
+    case []interface{}:
+        return appendNBTList(p, v)
+    case map[string] interface{}:
+        keys := make([]string, 0, len(v))
+        for key := range v {
+            if nbtType(v[key]) != tagEnd {
+                keys = append(keys, key)
+            }
+        }
+        sort.Strings(keys)
+        for _, key := range keys {
+            p = append(p, nbtType(v[key]))
+            p = appendNBTString(p, key)
+            p = appendNBTPayload(p, v[key])
+        }
+        return append(p, tagEnd)
+    case []int32:
+        p = be.AppendUint32(p, uint32(len(v)))
+        for _, x := range v {
+            p = be.AppendUint32(p, uint32(x))
+        }
+        return p
+    case []int64:
+        p = be.AppendUint32(p, uint32(len(v)))
+        for _, x := range v {
+            p = be.AppendUint64(p, uint64(x))
+        }
+        return p
+    }
+
+    return p
+
+}
+
+// appendNBTList wraps the elements in compounds with an empty key when
+// they are not of one type, as lists in NBT cannot mix types
+func appendNBTList(p []byte, vs []interface{}) []byte {
+
+    typ := byte(tagEnd)
+    for i, v := range vs {
+        if i == 0 {
+            typ = nbtType(v)
+        } else if nbtType(v) != typ {
+            typ = 0xff
+            break
+        }
+    }
+
+    if typ == 0xff {
+        wrapped := make([]interface{}, len(vs))
+        for i, v := range vs {
+            if nbtType(v) == tagCompound {
+                wrapped[i] = v
+            } else {
+                wrapped[i] = map[string] interface{}{"": v}
+            }
+        }
+        return appendNBTList(p, wrapped)
+    }
+
+    p = append(p, typ)
+    p = binary.BigEndian.AppendUint32(p, uint32(len(vs)))
+    for _, v := range vs {
+        p = appendNBTPayload(p, v)
+    }
+
+    return p
+
+}
+
+// ReadNBT reads a nameless tag, an End tag being nil. Lengths are checked
+// against what is left in the reader.
+func ReadNBT(r *bytes.Reader) (interface{}, error) {
+
+    typ, err := r.ReadByte()
+    if err != nil {
+        return nil, err
+    }
+    if typ == tagEnd {
+        return nil, nil
+    }
+
+    nr := &nbtReader{r: r}
+    v := nr.payload(typ, 0)
+
+    return v, nr.err
+
+}
+
+type nbtReader struct {
+    r *bytes.Reader
+    err error
+}
+
+func(nr *nbtReader) fail(err error) {
+    if nr.err == nil {
+        nr.err = err
+    }
+}
+
+func(nr *nbtReader) nextBytes(n int) []byte {
+    if nr.err != nil {
+        return nil
+    }
+    if n < 0 || n > nr.r.Len() {
+        nr.fail(io.ErrUnexpectedEOF)
+        return nil
+    }
+    p := make([]byte, n)
+    io.ReadFull(nr.r, p)
+    return p
+}
+
+func(nr *nbtReader) next(n int) uint64 {
+    p := nr.nextBytes(n)
+    x := uint64(0)
+    for _, b := range p {
+        x = x << 8 | uint64(b)
+    }
+    return x
+}
+
+// length reads an array length, failing if the elements cannot fit
+func(nr *nbtReader) length(size int) int {
+    n := int32(nr.next(4))
+    if nr.err == nil && (n < 0 || int(n) > nr.r.Len() / size) {
+        nr.fail(io.ErrUnexpectedEOF)
+    }
+    if nr.err != nil {
+        return 0
+    }
+    return int(n)
+}
+
+func(nr *nbtReader) nextString() string {
+
+    p := nr.nextBytes(int(nr.next(2)))
+    units := make([]uint16, 0, len(p))
+    for i := 0; i < len(p); {
+        b := p[i]
+        switch {
+        case b < 0x80:
+            units = append(units, uint16(b))
+            i++
+        case b & 0xe0 == 0xc0 && i + 1 < len(p):
+            units = append(units, uint16(b & 0x1f) << 6 | uint16(p[i + 1] & 0x3f))
+            i += 2
+        case b & 0xf0 == 0xe0 && i + 2 < len(p):
+            units = append(units, uint16(b & 0x0f) << 12 | uint16(p[i + 1] & 0x3f) << 6 | uint16(p[i + 2] & 0x3f))
+            i += 3
+        default:
+            nr.fail(fmt.Errorf("Invalid modified UTF-8 %x", p))
+            return ""
+        }
+    }
+
+    return string(utf16.Decode(units))
+
+}
+
+func(nr *nbtReader) payload(typ byte, depth int) interface{} {
+
+    if depth > nbtMaxDepth {
+        nr.fail(fmt.Errorf("NBT deeper than %d", nbtMaxDepth))
+        return nil
+    }
+
+    switch typ {
+    case tagByte:
+        return int8(nr.next(1))
+    case tagShort:
+        return int16(nr.next(2))
+    case tagInt:
+        return int32(nr.next(4))
+    case tagLong:
+        return int64(nr.next(8))
+    case tagFloat:
+        return math.Float32frombits(uint32(nr.next(4)))
+    case tagDouble:
+        return math.Float64frombits(nr.next(8))
+    case tagByteArray:
+        return nr.nextBytes(nr.length(1))
+    case tagString:
+        return nr.nextString()
+    case tagList:
+        elem := byte(nr.next(1))
+        n := nr.length(1)
+        if elem == tagEnd && n > 0 {
+            nr.fail(fmt.Errorf("List of End tags"))
+        }
+        list := make([]interface{}, 0, n)
+        for i := 0; i < n && nr.err == nil; i++ {
+            list = append(list, nr.payload(elem, depth + 1))
+        }
+        return list
+    case tagCompound:
+        compound := make(map[string] interface{})
+        for nr.err == nil {
+            typ := byte(nr.next(1))
+            if typ == tagEnd {
+                break
+            }
+            name := nr.nextString()
+            compound[name] = nr.payload(typ, depth + 1)
+        }
+        return compound
+    case tagIntArray:
+        arr := make([]int32, nr.length(4))
+        for i := range arr {
+            arr[i] = int32(nr.next(4))
+        }
+        return arr
+    case tagLongArray:
+        arr := make([]int64, nr.length(8))
+        for i := range arr {
+            arr[i] = int64(nr.next(8))
+        }
+        return arr
+    }
+
+    nr.fail(fmt.Errorf("Unknown NBT tag %d", typ))
+    return nil
+
+}
@@ -0,0 +1,67 @@
+package packet
+
+import (
+    "bytes"
+    "reflect"
+    "testing"
+)
+
+func TestNBT(t *testing.T) {
+
+    samples := []struct {
+        v interface{}
+        p []byte
+    }{
+        {int8(-1), []byte{tagByte, 0xff}},
+        {int16(256), []byte{tagShort, 0x01, 0x00}},
+        {int32(-2), []byte{tagInt, 0xff, 0xff, 0xff, 0xfe}},
+        {int64(1), []byte{tagLong, 0, 0, 0, 0, 0, 0, 0, 1}},
+        {float32(1.5), []byte{tagFloat, 0x3f, 0xc0, 0x00, 0x00}},
+        {float64(-2), []byte{tagDouble, 0xc0, 0, 0, 0, 0, 0, 0, 0}},
+        {[]byte{1, 2}, []byte{tagByteArray, 0, 0, 0, 2, 1, 2}},
+        {"a\x00é😀", []byte{tagString, 0, 11, 'a', 0xc0, 0x80, 0xc3, 0xa9, 0xed, 0xa0, 0xbd, 0xed, 0xb8, 0x80}},
+        {[]interface{}{int16(1), int16(2)}, []byte{tagList, tagShort, 0, 0, 0, 2, 0, 1, 0, 2}},
+        {[]interface{}{}, []byte{tagList, tagEnd, 0, 0, 0, 0}},
+        {map[string] interface{}{"b": "x", "a": []int32{7}}, []byte{
+            tagCompound,
+            tagIntArray, 0, 1, 'a', 0, 0, 0, 1, 0, 0, 0, 7,
+            tagString, 0, 1, 'b', 0, 1, 'x',
+            tagEnd,
+        }},
+        {[]int64{-1}, []byte{tagLongArray, 0, 0, 0, 1, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+    }
+
+    for _, sample := range samples {
+        p := AppendNBT(nil, sample.v)
+        if !bytes.Equal(p, sample.p) {
+            t.Errorf("%v) %x != %x", sample.v, p, sample.p)
+        }
+
+        v, err := ReadNBT(bytes.NewReader(sample.p))
+        if err != nil || !reflect.DeepEqual(v, sample.v) {
+            t.Errorf("%x) %#v != %#v, %v", sample.p, v, sample.v, err)
+        }
+    }
+
+    // Mixed lists are wrapped
+    p := AppendNBT(nil, []interface{}{"a", map[string] interface{}{}})
+    expected := []byte{tagList, tagCompound, 0, 0, 0, 2, tagString, 0, 0, 0, 1, 'a', tagEnd, tagEnd}
+    if !bytes.Equal(p, expected) {
+        t.Errorf("%x != %x", p, expected)
+    }
+
+    // Bounded
+    for _, p := range [][]byte{
+        {tagByteArray, 0x7f, 0xff, 0xff, 0xff},
+        {tagList, tagEnd, 0, 0, 0, 1},
+        {tagCompound, tagString, 0, 1},
+        {0x0d},
+        append(append([]byte{tagList}, bytes.Repeat([]byte{tagList, 0, 0, 0, 1}, 600)...), tagEnd, 0, 0, 0, 0),
+    } {
+        _, err := ReadNBT(bytes.NewReader(p))
+        if err == nil {
+            t.Errorf("%x) no error", p)
+        }
+    }
+
+}
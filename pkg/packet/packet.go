@@ -3,7 +3,10 @@ package packet
 import (
     "bytes"
     "encoding/binary"
+    "errors"
+    "fmt"
     "io"
+    "math"
 )
 
 // Wrapper
@@ -13,7 +16,7 @@ type reader struct {
 
 func(r reader) ReadByte() (byte, error) {
     p := make([]byte, 1)
-    _, err := r.Reader.Read(p)
+    _, err := io.ReadFull(r.Reader, p)
     if err != nil {
         return 0, err
     }
@@ -25,56 +28,163 @@ type Reader interface {
     io.ByteReader
 }
 
-// PacketReader
-type PacketReader struct {
+// Decoder
+// Reads length prefixed frames without reading past them, so that the
+// rest of the connection can be piped as is
+var (
+    ErrFrameTooLarge = errors.New("Frame too large")
+    ErrVarIntTooLong = errors.New("VarInt too long")
+    ErrUnexpectedID = errors.New("Unexpected packet id")
+)
+
+// MaxFrameLength is the default limit of decoders, that of vanilla
+var MaxFrameLength = 1 << 21 - 1
+
+type Decoder struct {
     r Reader
+    MaxFrame int
 }
 
-func NewPacketReader(id int, ir io.Reader) *PacketReader {
+func NewDecoder(ir io.Reader) *Decoder {
+    r, ok := ir.(Reader)
+    if !ok {
+        r = reader{ir}
+    }
+    return &Decoder{r, MaxFrameLength}
+}
 
-    var r Reader = reader{ir}
+// ReadFrame reads the body of a frame, the packet id and data
+func(d *Decoder) ReadFrame() ([]byte, error) {
+
+    l, err := ReadVarInt(d.r)
+    if err != nil {
+        return nil, err
+    }
+    if l < 0 || int(l) > d.MaxFrame {
+        return nil, fmt.Errorf("%w: %d", ErrFrameTooLarge, l)
+    }
 
-    l := ReadVarInt(r)
     p := make([]byte, l)
-    n, err := io.ReadFull(r, p)
+    _, err = io.ReadFull(d.r, p)
+    if err == io.EOF && l > 0 {
+        err = io.ErrUnexpectedEOF
+    }
+
+    return p, err
+
+}
+
+// ReadPacket reads a whole packet regardless of its id
+func(d *Decoder) ReadPacket() (int, *PacketReader, error) {
+
+    p, err := d.ReadFrame()
     if err != nil {
-        panic(err)
+        return 0, nil, err
     }
-    if l != int32(n) {
-        panic("Wrong packet length")
+
+    pr := &PacketReader{r: bytes.NewReader(p)}
+    id := pr.NextVarInt()
+    if pr.err == io.EOF {
+        pr.err = io.ErrUnexpectedEOF
     }
 
-    r = bytes.NewReader(p)
-    id1 := ReadVarInt(r)
-    if id != int(id1) {
-        panic("Wrong packet id")
+    return int(id), pr, pr.err
+
+}
+
+// ReadPacketID reads a packet that has to be of the id
+func(d *Decoder) ReadPacketID(id int) (*PacketReader, error) {
+
+    id1, pr, err := d.ReadPacket()
+    if err != nil {
+        return nil, err
+    }
+    if id1 != id {
+        return nil, fmt.Errorf("%w: %#x, expected %#x", ErrUnexpectedID, id1, id)
     }
 
-    return &PacketReader{r}
+    return pr, nil
+
+}
 
+func NewPacketReader(id int, ir io.Reader) (*PacketReader, error) {
+    return NewDecoder(ir).ReadPacketID(id)
 }
 
-func(pr *PacketReader) readFull(p []byte) {
-    n, err := io.ReadFull(pr.r, p)
+// ReadRawPacket reads a whole packet including its length prefix
+func ReadRawPacket(ir io.Reader) ([]byte, error) {
+
+    p, err := NewDecoder(ir).ReadFrame()
     if err != nil {
-        panic(err)
+        return nil, err
+    }
+
+    return append(VarInt(int32(len(p))), p...), nil
+
+}
+
+// ReadAnyPacket reads a whole packet regardless of its id
+func ReadAnyPacket(ir io.Reader) (int, *PacketReader, error) {
+    return NewDecoder(ir).ReadPacket()
+}
+
+// PacketReader
+// Reads the fields of a packet. The first error sticks, making the rest of
+// the reads return zero values, and is reported by Err.
+type PacketReader struct {
+    r *bytes.Reader
+    err error
+}
+
+func(pr *PacketReader) Err() error {
+    return pr.err
+}
+
+func(pr *PacketReader) fail(err error) {
+    if pr.err == nil {
+        pr.err = err
     }
-    if n != len(p) {
-        panic("Read length mismatch")
+}
+
+// Len returns the number of unread bytes
+func(pr *PacketReader) Len() int {
+    return pr.r.Len()
+}
+
+func(pr *PacketReader) readFull(p []byte) bool {
+    if pr.err != nil {
+        return false
+    }
+    _, err := io.ReadFull(pr.r, p)
+    if err == io.EOF {
+        err = io.ErrUnexpectedEOF
     }
+    pr.fail(err)
+    return err == nil
 }
 
 func(pr *PacketReader) NextVarInt() int32 {
-    return ReadVarInt(pr.r)
+    if pr.err != nil {
+        return 0
+    }
+    x, err := ReadVarInt(pr.r)
+    if err == io.EOF {
+        err = io.ErrUnexpectedEOF
+    }
+    pr.fail(err)
+    return x
 }
 
 func(pr *PacketReader) NextInt(sz int) int64 {
     p := make([]byte, sz)
-    pr.readFull(p)
+    if !pr.readFull(p) {
+        return 0
+    }
 
     be := binary.BigEndian
     x := int64(0)
     switch sz {
+    case 1: x = int64(p[0])
     case 2: x = int64(be.Uint16(p))
     case 4: x = int64(be.Uint32(p))
     case 8: x = int64(be.Uint64(p))
@@ -84,11 +194,25 @@ func(pr *PacketReader) NextInt(sz int) int64 {
 
 func(pr *PacketReader) NextString() string {
     l := pr.NextVarInt()
+    if pr.err != nil {
+        return ""
+    }
+    if l < 0 || int(l) > pr.r.Len() {
+        pr.fail(io.ErrUnexpectedEOF)
+        return ""
+    }
     p := make([]byte, l)
     pr.readFull(p)
     return string(p)
 }
 
+// Rest reads the remaining bytes of the packet
+func(pr *PacketReader) Rest() []byte {
+    p := make([]byte, pr.r.Len())
+    pr.readFull(p)
+    return p
+}
+
 // Packet
 type Packet struct {
     id int
@@ -116,6 +240,7 @@ func(pk *Packet) PutInt(x int64, sz int) {
     p := make([]byte, sz)
     be := binary.BigEndian
     switch sz {
+    case 1: p[0] = byte(x)
     case 2: be.PutUint16(p, uint16(x))
     case 4: be.PutUint32(p, uint32(x))
     case 8: be.PutUint64(p, uint64(x))
@@ -130,6 +255,26 @@ func(pk *Packet) PutString(s string) {
     pk.put(p)
 }
 
+func(pk *Packet) PutBool(b bool) {
+    if b {
+        pk.put([]byte{1})
+    } else {
+        pk.put([]byte{0})
+    }
+}
+
+func(pk *Packet) PutFloat(f float32) {
+    pk.PutInt(int64(math.Float32bits(f)), 4)
+}
+
+func(pk *Packet) PutDouble(f float64) {
+    pk.PutInt(int64(math.Float64bits(f)), 8)
+}
+
+func(pk *Packet) PutUUID(uuid [16]byte) {
+    pk.put(uuid[:])
+}
+
 
 // Varint
 func varint(x uint64, sz int) []byte {
@@ -153,18 +298,34 @@ func VarLong(x int64) []byte {
     return varint(uint64(x), 8)
 }
 
-func readVarint(r io.ByteReader) uint64 {
-    n, err := binary.ReadUvarint(r)
-    if err != nil {
-        panic(err)
+// readVarint reads at most n bytes, as many as the type needs
+func readVarint(r io.ByteReader, n int) (uint64, error) {
+
+    var x uint64
+    for i := 0; i < n; i++ {
+        b, err := r.ReadByte()
+        if err != nil {
+            if i > 0 && err == io.EOF {
+                err = io.ErrUnexpectedEOF
+            }
+            return 0, err
+        }
+        x |= uint64(b & 0x7f) << (7 * i)
+        if b & 0x80 == 0 {
+            return x, nil
+        }
     }
-    return n
+
+    return 0, ErrVarIntTooLong
+
 }
 
-func ReadVarInt(r io.ByteReader) int32 {
-    return int32(readVarint(r))
+func ReadVarInt(r io.ByteReader) (int32, error) {
+    x, err := readVarint(r, 5)
+    return int32(x), err
 }
 
-func ReadVarLong(r io.ByteReader) int64 {
-    return int64(readVarint(r))
+func ReadVarLong(r io.ByteReader) (int64, error) {
+    x, err := readVarint(r, 10)
+    return int64(x), err
 }
@@ -2,6 +2,8 @@ package packet
 
 import (
     "bytes"
+    "errors"
+    "io"
     "testing"
 )
 
@@ -47,3 +49,100 @@ func TestVarInts(t *testing.T) {
     }
 
 }
+
+func TestDecoderErrors(t *testing.T) {
+
+    samples := []struct {
+        in []byte
+        expected error
+    }{
+        {[]byte{0xff, 0xff, 0xff, 0xff, 0x07}, ErrFrameTooLarge},
+        {[]byte{0x80, 0x80, 0x80, 0x80, 0x80, 0x01}, ErrVarIntTooLong},
+        {[]byte{0x02, 0x05, 0x00}, ErrUnexpectedID},
+        {[]byte{0x05, 0x00}, io.ErrUnexpectedEOF},
+        {[]byte{0x00}, io.ErrUnexpectedEOF},
+        {[]byte{}, io.EOF},
+    }
+
+    for _, sample := range samples {
+        _, err := NewPacketReader(0x00, bytes.NewReader(sample.in))
+        t.Logf("%x) %v", sample.in, err)
+        if !errors.Is(err, sample.expected) {
+            t.Errorf("%x) %v is not %v", sample.in, err, sample.expected)
+        }
+    }
+
+    // Configurable limit
+    dec := NewDecoder(bytes.NewReader([]byte{0x03, 0x00, 0x01, 0x02}))
+    dec.MaxFrame = 2
+    _, _, err := dec.ReadPacket()
+    if !errors.Is(err, ErrFrameTooLarge) {
+        t.Error(err)
+    }
+
+    // Strings longer than the packet
+    pk := NewPacket(0x00)
+    pk.PutVarInt(100)
+    _, err = ReadLoginStart(bytes.NewReader(pk.Bytes()))
+    if !errors.Is(err, io.ErrUnexpectedEOF) {
+        t.Error(err)
+    }
+
+}
+
+func TestReadVarInts(t *testing.T) {
+
+    samples := map[int32] []byte{
+        0:           []byte{0x00},
+        127:         []byte{0x7f},
+        128:         []byte{0x80, 0x01},
+        25565:       []byte{0xdd, 0xc7, 0x01},
+        2147483647:  []byte{0xff, 0xff, 0xff, 0xff, 0x07},
+        -1:          []byte{0xff, 0xff, 0xff, 0xff, 0x0f},
+        -2147483648: []byte{0x80, 0x80, 0x80, 0x80, 0x08},
+    }
+
+    for x, p := range samples {
+        v, err := ReadVarInt(bytes.NewReader(p))
+        if err != nil || v != x {
+            t.Errorf("%x) %d != %d, %v", p, v, x, err)
+        }
+    }
+
+    // Bits beyond 32 wrap around as in vanilla
+    v, err := ReadVarInt(bytes.NewReader([]byte{0xff, 0xff, 0xff, 0xff, 0x7f}))
+    if err != nil || v != -1 {
+        t.Errorf("%d, %v", v, err)
+    }
+
+}
+
+func TestVarLongs(t *testing.T) {
+
+    samples := map[int64] []byte{
+        0:                    []byte{0x00},
+        127:                  []byte{0x7f},
+        128:                  []byte{0x80, 0x01},
+        2147483647:           []byte{0xff, 0xff, 0xff, 0xff, 0x07},
+        9223372036854775807:  []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x7f},
+        -1:                   []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01},
+        -2147483648:          []byte{0x80, 0x80, 0x80, 0x80, 0xf8, 0xff, 0xff, 0xff, 0xff, 0x01},
+        -9223372036854775808: []byte{0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x01},
+    }
+
+    for x, p := range samples {
+        if v := VarLong(x); !bytes.Equal(v, p) {
+            t.Errorf("%d) %x != %x", x, v, p)
+        }
+        v, err := ReadVarLong(bytes.NewReader(p))
+        if err != nil || v != x {
+            t.Errorf("%x) %d != %d, %v", p, v, x, err)
+        }
+    }
+
+    _, err := ReadVarLong(bytes.NewReader(bytes.Repeat([]byte{0x80}, 11)))
+    if !errors.Is(err, ErrVarIntTooLong) {
+        t.Error(err)
+    }
+
+}
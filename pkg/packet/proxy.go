@@ -0,0 +1,303 @@
+package packet
+
+import (
+    "bufio"
+    "bytes"
+    "encoding/binary"
+    "fmt"
+    "io"
+    "net"
+    "strconv"
+    "strings"
+)
+
+// PROXY protocol
+// https://www.haproxy.org/download/2.0/doc/proxy-protocol.txt
+var (
+    proxyV1Prefix = []byte("PROXY ")
+    proxyV2Sig = []byte("\r\n\r\n\x00\r\nQUIT\n")
+)
+
+const (
+    proxyV1MaxLength = 107
+    proxyV2Proxy = 0x21
+    proxyV2Local = 0x20
+    proxyV2TCP4 = 0x11
+    proxyV2TCP6 = 0x21
+)
+
+// WriteProxyHeader writes a PROXY protocol header of the given version
+// telling the other end that the connection came from src to dst
+func WriteProxyHeader(w io.Writer, version int, src, dst net.Addr) error {
+
+    var p []byte
+    switch version {
+    case 1: p = proxyHeaderV1(src, dst)
+    case 2: p = proxyHeaderV2(src, dst)
+    default: return fmt.Errorf("Unknown PROXY protocol version %d", version)
+    }
+
+    _, err := w.Write(p)
+    return err
+
+}
+
+func proxyAddrs(src, dst net.Addr) (*net.TCPAddr, *net.TCPAddr, bool) {
+    tsrc, ok1 := src.(*net.TCPAddr)
+    tdst, ok2 := dst.(*net.TCPAddr)
+    return tsrc, tdst, ok1 && ok2
+}
+
+func proxyHeaderV1(src, dst net.Addr) []byte {
+
+    tsrc, tdst, ok := proxyAddrs(src, dst)
+    if !ok {
+        return []byte("PROXY UNKNOWN\r\n")
+    }
+
+    proto := "TCP4"
+    srcip, dstip := tsrc.IP.To4(), tdst.IP.To4()
+    if srcip == nil || dstip == nil {
+        proto = "TCP6"
+        srcip, dstip = tsrc.IP.To16(), tdst.IP.To16()
+    }
+
+    return []byte(fmt.Sprintf("PROXY %s %s %s %d %d\r\n",
+        proto, srcip, dstip, tsrc.Port, tdst.Port))
+
+}
+
+func proxyHeaderV2(src, dst net.Addr) []byte {
+
+    p := append([]byte{}, proxyV2Sig...)
+
+    tsrc, tdst, ok := proxyAddrs(src, dst)
+    if !ok {
+        return append(p, proxyV2Local, 0x00, 0x00, 0x00)
+    }
+
+    fam := byte(proxyV2TCP4)
+    srcip, dstip := tsrc.IP.To4(), tdst.IP.To4()
+    if srcip == nil || dstip == nil {
+        fam = proxyV2TCP6
+        srcip, dstip = tsrc.IP.To16(), tdst.IP.To16()
+    }
+
+    body := append(append([]byte{}, srcip...), dstip...)
+    body = append(body, byte(tsrc.Port >> 8), byte(tsrc.Port))
+    body = append(body, byte(tdst.Port >> 8), byte(tdst.Port))
+
+    p = append(p, proxyV2Proxy, fam, byte(len(body) >> 8), byte(len(body)))
+    return append(p, body...)
+
+}
+
+// proxyConn
+// Connection whose addresses were given by a PROXY header
+type proxyConn struct {
+    net.Conn
+    r *bufio.Reader
+    remote net.Addr
+    local net.Addr
+}
+
+func(pc *proxyConn) Read(p []byte) (int, error) {
+    return pc.r.Read(p)
+}
+
+func(pc *proxyConn) RemoteAddr() net.Addr {
+    return pc.remote
+}
+
+func(pc *proxyConn) LocalAddr() net.Addr {
+    return pc.local
+}
+
+// AcceptProxyHeader reads an optional PROXY header of either version from
+// the connection and returns a connection that reports the addresses in it
+func AcceptProxyHeader(conn net.Conn) (net.Conn, error) {
+
+    pc := &proxyConn{
+        Conn: conn,
+        r: bufio.NewReader(conn),
+        remote: conn.RemoteAddr(),
+        local: conn.LocalAddr(),
+    }
+
+    b, err := pc.r.Peek(1)
+    if err != nil {
+        return nil, err
+    }
+
+    var src, dst net.Addr
+    switch b[0] {
+    case proxyV1Prefix[0]:
+        p, err := pc.r.Peek(len(proxyV1Prefix))
+        if err != nil || !bytes.Equal(p, proxyV1Prefix) {
+            return pc, nil
+        }
+        src, dst, err = readProxyHeaderV1(pc.r)
+        if err != nil {
+            return nil, err
+        }
+    case proxyV2Sig[0]:
+        p, err := pc.r.Peek(len(proxyV2Sig))
+        if err != nil || !bytes.Equal(p, proxyV2Sig) {
+            return pc, nil
+        }
+        src, dst, err = readProxyHeaderV2(pc.r)
+        if err != nil {
+            return nil, err
+        }
+    default:
+        return pc, nil
+    }
+
+    if src != nil && dst != nil {
+        pc.remote, pc.local = src, dst
+    }
+
+    return pc, nil
+
+}
+
+func readProxyHeaderV1(r *bufio.Reader) (src, dst net.Addr, err error) {
+
+    var line []byte
+    for len(line) < proxyV1MaxLength {
+        b, err := r.ReadByte()
+        if err != nil {
+            return nil, nil, err
+        }
+        line = append(line, b)
+        if b == '\n' {
+            break
+        }
+    }
+    if !bytes.HasSuffix(line, []byte("\r\n")) {
+        return nil, nil, fmt.Errorf("Malformed PROXY v1 header")
+    }
+
+    fields := strings.Fields(string(line))
+    if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+        return nil, nil, nil
+    }
+    if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+        return nil, nil, fmt.Errorf("Malformed PROXY v1 header")
+    }
+
+    parse := func(ipstr, portstr string) (*net.TCPAddr, error) {
+        ip := net.ParseIP(ipstr)
+        port, err := strconv.ParseUint(portstr, 10, 16)
+        if ip == nil || err != nil {
+            return nil, fmt.Errorf("Malformed PROXY v1 address")
+        }
+        return &net.TCPAddr{IP: ip, Port: int(port)}, nil
+    }
+
+    tsrc, err := parse(fields[2], fields[4])
+    if err != nil {
+        return nil, nil, err
+    }
+    tdst, err := parse(fields[3], fields[5])
+    if err != nil {
+        return nil, nil, err
+    }
+
+    return tsrc, tdst, nil
+
+}
+
+func readProxyHeaderV2(r *bufio.Reader) (src, dst net.Addr, err error) {
+
+    head := make([]byte, len(proxyV2Sig) + 4)
+    _, err = io.ReadFull(r, head)
+    if err != nil {
+        return nil, nil, err
+    }
+
+    cmd, fam := head[12], head[13]
+    body := make([]byte, binary.BigEndian.Uint16(head[14:]))
+    _, err = io.ReadFull(r, body)
+    if err != nil {
+        return nil, nil, err
+    }
+
+    if cmd >> 4 != 2 {
+        return nil, nil, fmt.Errorf("Wrong PROXY v2 version")
+    }
+    if cmd != proxyV2Proxy {
+        return nil, nil, nil // LOCAL
+    }
+
+    var iplen int
+    switch fam {
+    case proxyV2TCP4: iplen = net.IPv4len
+    case proxyV2TCP6: iplen = net.IPv6len
+    default: return nil, nil, nil // unsupported family, keep the socket addresses
+    }
+
+    if len(body) < iplen * 2 + 4 {
+        return nil, nil, fmt.Errorf("Malformed PROXY v2 header")
+    }
+
+    // Remaining bytes are TLVs, which are ignored
+    ports := body[iplen * 2:]
+    tsrc := &net.TCPAddr{
+        IP: net.IP(body[:iplen]),
+        Port: int(binary.BigEndian.Uint16(ports)),
+    }
+    tdst := &net.TCPAddr{
+        IP: net.IP(body[iplen:iplen * 2]),
+        Port: int(binary.BigEndian.Uint16(ports[2:])),
+    }
+
+    return tsrc, tdst, nil
+
+}
+
+// ParseCIDRs parses CIDRs and plain IP addresses
+func ParseCIDRs(strs []string) ([]*net.IPNet, error) {
+
+    nets := make([]*net.IPNet, 0, len(strs))
+    for _, str := range strs {
+        if !strings.Contains(str, "/") {
+            ip := net.ParseIP(str)
+            if ip == nil {
+                return nil, fmt.Errorf("Invalid IP address %s", str)
+            }
+            bits := net.IPv6len * 8
+            if ip.To4() != nil {
+                ip, bits = ip.To4(), net.IPv4len * 8
+            }
+            nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+            continue
+        }
+
+        _, ipnet, err := net.ParseCIDR(str)
+        if err != nil {
+            return nil, err
+        }
+        nets = append(nets, ipnet)
+    }
+
+    return nets, nil
+
+}
+
+func trusted(addr net.Addr, nets []*net.IPNet) bool {
+
+    taddr, ok := addr.(*net.TCPAddr)
+    if !ok {
+        return false
+    }
+
+    for _, ipnet := range nets {
+        if ipnet.Contains(taddr.IP) {
+            return true
+        }
+    }
+
+    return false
+
+}
@@ -0,0 +1,130 @@
+package packet
+
+import (
+    "bufio"
+    "bytes"
+    "net"
+    "testing"
+)
+
+func TestProxyHeaders(t *testing.T) {
+
+    samples := []struct{
+        src, dst *net.TCPAddr
+    }{
+        {
+            &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 51234},
+            &net.TCPAddr{IP: net.ParseIP("198.51.100.7"), Port: 25565},
+        },
+        {
+            &net.TCPAddr{IP: net.ParseIP("2001:db8::1"), Port: 40000},
+            &net.TCPAddr{IP: net.ParseIP("2001:db8::2"), Port: 25565},
+        },
+    }
+
+    for _, version := range []int{1, 2} {
+        for _, sample := range samples {
+            a, b := net.Pipe()
+            payload := []byte{0x10, 0x00}
+
+            go func() {
+                WriteProxyHeader(a, version, sample.src, sample.dst)
+                a.Write(payload)
+            }()
+
+            conn, err := AcceptProxyHeader(b)
+            if err != nil {
+                t.Fatal(version, err)
+            }
+
+            p := make([]byte, len(payload))
+            conn.Read(p)
+            ok := conn.RemoteAddr().String() == sample.src.String() &&
+                conn.LocalAddr().String() == sample.dst.String() &&
+                bytes.Equal(p, payload)
+            t.Logf("v%d) %s -> %s, %t", version, conn.RemoteAddr(), conn.LocalAddr(), ok)
+            if !ok {
+                t.Fail()
+            }
+
+            a.Close()
+            b.Close()
+        }
+    }
+
+}
+
+func TestProxyHeaderAbsent(t *testing.T) {
+
+    a, b := net.Pipe()
+    hs := Handshake{Protocol: 754, Address: "PROXY", Port: 25565, NextState: StateStatus}
+    go a.Write(hs.Bytes())
+
+    conn, err := AcceptProxyHeader(b)
+    if err != nil {
+        t.Fatal(err)
+    }
+    hs1, err := ReadHandshake(conn)
+    if err != nil || hs1 != hs {
+        t.Errorf("%+v != %+v, %v", hs1, hs, err)
+    }
+
+}
+
+// proxyOnlyServer answers statuses only to connections that start with a
+// PROXY header
+func proxyOnlyServer(t *testing.T, rsp Response) net.Listener {
+
+    ln, err := net.Listen("tcp", "127.0.0.1:0")
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    go func() {
+        for {
+            conn, err := ln.Accept()
+            if err != nil {
+                return
+            }
+            go func() {
+                defer conn.Close()
+                r := bufio.NewReader(conn)
+                p, err := r.Peek(len(proxyV2Sig))
+                if err != nil || !bytes.HasPrefix(p, proxyV1Prefix) && !bytes.Equal(p, proxyV2Sig) {
+                    return
+                }
+                pc, err := AcceptProxyHeader(&proxyConn{conn, r, conn.RemoteAddr(), conn.LocalAddr()})
+                if err != nil {
+                    return
+                }
+                hs, err := ReadHandshake(pc)
+                if err != nil {
+                    return
+                }
+                ServeResponse(pc, hs, rsp)
+            }()
+        }
+    }()
+
+    return ln
+
+}
+
+func TestStatusProxy(t *testing.T) {
+
+    rsp := Response{Description: Chat{Text: "Proxied"}}
+    ln := proxyOnlyServer(t, rsp)
+    defer ln.Close()
+    addr := ln.Addr().String()
+
+    if _, err := Status(addr); err == nil {
+        t.Error("Status without a PROXY header was answered")
+    }
+    for _, version := range []int{1, 2} {
+        got, err := StatusProxy(addr, version)
+        if err != nil || got.Description.Text != "Proxied" {
+            t.Errorf("v%d) %+v %v", version, got, err)
+        }
+    }
+
+}
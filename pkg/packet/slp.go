@@ -27,18 +27,29 @@ type Handshake struct {
     Address string
     Port uint16
     NextState int32
+    Legacy bool // a legacy ping, which is never on the wire as a handshake
 }
 
 func ReadHandshake(rd io.Reader) (hs Handshake, err error) {
 
-    defer act.CatchAndStore(&err)
-
-    pr := NewPacketReader(IDHandshake, rd)
+    pr, err := NewPacketReader(IDHandshake, rd)
+    if err != nil {
+        return
+    }
 
     hs.Protocol = pr.NextVarInt()
     hs.Address = pr.NextString()
     hs.Port = uint16(pr.NextInt(2))
     hs.NextState = pr.NextVarInt()
+    if pr.Err() != nil {
+        return hs, pr.Err()
+    }
+
+    switch hs.NextState {
+    case StateStatus, StateLogin, StateTransfer:
+    default:
+        return hs, fmt.Errorf("Unknown next state %d", hs.NextState)
+    }
 
     return hs, nil
 
@@ -63,11 +74,9 @@ type Request struct {
 
 func ReadRequest(rd io.Reader) (req Request, err error) {
 
-    defer act.CatchAndStore(&err)
+    _, err = NewPacketReader(IDStatusSLP, rd)
 
-    NewPacketReader(IDStatusSLP, rd)
-
-    return req, nil
+    return req, err
 
 }
 
@@ -98,24 +107,36 @@ type (
         Version VersionStruct `json:"version"`
         Players PlayersStruct `json:"players"`
         Description Chat `json:"description"`
+        Favicon string `json:"favicon,omitempty"` // data:image/png;base64,...
+        ForgeData json.RawMessage `json:"forgeData,omitempty"` // mods and channels of Forge 1.13+
+        ModInfo json.RawMessage `json:"modinfo,omitempty"` // mods of Forge before 1.13
     }
 )
 
 func ReadResponse(rd io.Reader) (rsp Response, err error) {
 
-    defer act.CatchAndStore(&err)
-
-    pr := NewPacketReader(IDHandshake, rd)
+    pr, err := NewPacketReader(IDHandshake, rd)
+    if err != nil {
+        return
+    }
 
     js := pr.NextString()
+    if pr.Err() != nil {
+        return rsp, pr.Err()
+    }
     err = json.Unmarshal([]byte(js), &rsp)
-    act.Try(err)
 
-    return rsp, nil
+    return rsp, err
+
+}
 
+func Status(addr string) (Response, error) {
+    return StatusProxy(addr, 0)
 }
 
-func Status(addr string) (rsp Response, err error) {
+// StatusProxy is Status over a connection that starts with a PROXY header
+// of the given version, for backends that require one; 0 sends none
+func StatusProxy(addr string, version int) (rsp Response, err error) {
 
     defer act.CatchAndStore(&err)
 
@@ -136,6 +157,10 @@ func Status(addr string) (rsp Response, err error) {
     act.Try(err)
     defer conn.Close()
 
+    if version > 0 {
+        act.Try(WriteProxyHeader(conn, version, conn.LocalAddr(), conn.RemoteAddr()))
+    }
+
     conn.Write(hs.Bytes())
 
     // Status request
@@ -161,6 +186,23 @@ func Status(addr string) (rsp Response, err error) {
 
 }
 
+// Overlay keeps what identifies the server in the response, replacing the
+// second line of the description with the banner
+func(rsp Response) Overlay(banner Chat) Response {
+
+    rsp.Players.Online = 0
+    rsp.Players.Sample = nil
+
+    // Siblings of an empty parent, so that neither inherits the other's style
+    banner.Text = "\n" + banner.Text
+    rsp.Description = Chat{
+        Extra: []Chat{rsp.Description.FirstLine(), banner},
+    }
+
+    return rsp
+
+}
+
 func(rsp Response) Bytes() []byte {
 
     pk := NewPacket(IDHandshake)
@@ -182,13 +224,14 @@ type PingPong struct {
 
 func ReadPingPong(rd io.Reader) (pp PingPong, err error) {
 
-    defer act.CatchAndStore(&err)
-
-    pr := NewPacketReader(IDStatusPingPong, rd)
+    pr, err := NewPacketReader(IDStatusPingPong, rd)
+    if err != nil {
+        return
+    }
 
     pp.Payload = pr.NextInt(8)
 
-    return pp, nil
+    return pp, pr.Err()
 
 }
 
@@ -205,16 +248,20 @@ func(pp PingPong) Bytes() []byte {
 // Login - start (server-bound)
 type LoginStart struct {
     Name string
+    rest []byte // fields that differ by version, e.g. the uuid
 }
 
 func ReadLoginStart(rd io.Reader) (start LoginStart, err error) {
 
-    defer act.CatchAndStore(&err)
+    pr, err := NewPacketReader(IDLoginStart, rd)
+    if err != nil {
+        return
+    }
 
-    pr := NewPacketReader(IDLoginStart, rd)
     start.Name = pr.NextString()
+    start.rest = pr.Rest()
 
-    return start, nil
+    return start, pr.Err()
 
 }
 
@@ -223,6 +270,7 @@ func(start LoginStart) Bytes() []byte {
     pk := NewPacket(IDLoginStart)
 
     pk.PutString(start.Name)
+    pk.put(start.rest)
 
     return pk.Bytes()
 
@@ -235,9 +283,11 @@ type Disconnect struct {
 
 func ReadDisconnect(rd io.Reader) (dc Disconnect, err error) {
 
-    defer act.CatchAndStore(&err)
+    pr, err := NewPacketReader(IDLoginDisconnect, rd)
+    if err != nil {
+        return
+    }
 
-    pr := NewPacketReader(IDLoginDisconnect, rd)
     dc.Reason, err = ReadChat(pr)
 
     return dc, err
@@ -1,21 +1,16 @@
 package packet
 
 import (
+    "bytes"
     "encoding/json"
-    "fmt"
+    "net"
+    "strings"
     "testing"
 )
 
 func TestSLPDo(t *testing.T) {
 
-    req := Request{
-        Protocol: -1,
-        Address: "localhost",
-        Port: 25565,
-        NextState: StateStatus,
-    }
-
-    rsp, err := Do(req)
+    rsp, err := Status("localhost:25565")
     t.Log(string(rsp.Bytes()), err)
 
 }
@@ -32,10 +27,77 @@ func TestSLPHandler(t *testing.T) {
         panic(err)
     }
 
-    handler := HandlerFunc(func(rw ResponseWriter, req Request) {
-        fmt.Println(req)
+    // Listening before serving, so that the status cannot come too early
+    ln, err := net.Listen("tcp", "localhost:0")
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer ln.Close()
+
+    handler := HandlerFunc(func(conn net.Conn, hs Handshake) {
+        t.Log(hs)
+        ServeResponse(conn, hs, rsp)
     })
 
-    ListenAndServe("localhost:25565", handler)
+    go Serve(ln, nil, handler)
+
+    got, err := Status(ln.Addr().String())
+    if err != nil {
+        t.Fatal(err)
+    }
+    if got.Version != rsp.Version || got.Description.String() != rsp.Description.String() {
+        t.Errorf("%+v != %+v", got, rsp)
+    }
+
+}
+
+func TestReadHandshake(t *testing.T) {
+
+    for _, state := range []int32{StateStatus, StateLogin, StateTransfer} {
+        hs := Handshake{Protocol: 767, Address: "mc.example.com", Port: 25565, NextState: state}
+        got, err := ReadHandshake(bytes.NewReader(hs.Bytes()))
+        if err != nil || got != hs {
+            t.Errorf("%+v != %+v, %v", got, hs, err)
+        }
+    }
+
+    // Not to be taken for anything else, such as a legacy ping
+    for _, state := range []int32{0, 4, 0xfe} {
+        hs := Handshake{Protocol: 767, Address: "mc.example.com", Port: 25565, NextState: state}
+        if _, err := ReadHandshake(bytes.NewReader(hs.Bytes())); err == nil {
+            t.Errorf("Next state %d was accepted", state)
+        }
+    }
+
+}
+
+func TestResponseOverlay(t *testing.T) {
+
+    example := `{"version":{"name":"Paper 1.21","protocol":767},
+"players":{"max":20,"online":3,"sample":[{"name":"Steve","id":"8667ba71-b85a-4004-af54-457a9734eed7"}]},
+"description":{"extra":[{"text":"My Server\n"},{"text":"Survival"}],"text":""},
+"favicon":"data:image/png;base64,AAAA",
+"forgeData":{"channels":[],"mods":[],"fmlNetworkVersion":3}}`
+
+    var rsp Response
+    err := json.Unmarshal([]byte(example), &rsp)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    banner := Chat{Text: "Sleeping", Color: "red"}
+    over := rsp.Overlay(banner)
+
+    desc := over.Description.String()
+    if desc != "My Server\nSleeping" {
+        t.Errorf("%q", desc)
+    }
+    if over.Players.Online != 0 || over.Players.Sample != nil || over.Players.Max != 20 {
+        t.Errorf("%+v", over.Players)
+    }
+    if over.Version != rsp.Version || over.Favicon != rsp.Favicon ||
+        !strings.Contains(string(over.Bytes()), `"fmlNetworkVersion":3`) {
+        t.Errorf("%+v", over)
+    }
 
 }
@@ -0,0 +1,304 @@
+package packet
+
+import (
+    "fmt"
+    "io"
+    "math"
+    "strings"
+)
+
+// Types
+// Data types of the protocol, written by Packet and read by PacketReader
+// https://minecraft.wiki/w/Java_Edition_protocol/Data_types
+
+// Position is a block position packed in a long, 26 bits for x and z and
+// 12 bits for y
+type Position struct {
+    X, Y, Z int32
+}
+
+func(pos Position) pack() uint64 {
+    return uint64(pos.X) & 0x3ffffff << 38 |
+        uint64(pos.Z) & 0x3ffffff << 12 |
+        uint64(pos.Y) & 0xfff
+}
+
+func unpackPosition(v uint64) Position {
+    x := int64(v)
+    return Position{
+        X: int32(x >> 38),
+        Y: int32(x << 52 >> 52),
+        Z: int32(x << 26 >> 38),
+    }
+}
+
+// BitSet is a set of bits sent as a prefixed array of longs
+type BitSet []uint64
+
+func(bs BitSet) Get(i int) bool {
+    if i < 0 || i / 64 >= len(bs) {
+        return false
+    }
+    return bs[i / 64] & (1 << uint(i % 64)) != 0
+}
+
+func(bs *BitSet) Set(i int, b bool) {
+    for i / 64 >= len(*bs) {
+        *bs = append(*bs, 0)
+    }
+    if b {
+        (*bs)[i / 64] |= 1 << uint(i % 64)
+    } else {
+        (*bs)[i / 64] &^= 1 << uint(i % 64)
+    }
+}
+
+// ValidIdentifier reports whether the string is a namespace:path, the
+// namespace being optional
+func ValidIdentifier(s string) bool {
+
+    namespace, path := "minecraft", s
+    if i := strings.IndexByte(s, ':'); i >= 0 {
+        namespace, path = s[:i], s[i + 1:]
+    }
+
+    valid := func(str, extra string) bool {
+        for _, r := range str {
+            if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' ||
+                strings.ContainsRune("_-." + extra, r)) {
+                return false
+            }
+        }
+        return true
+    }
+
+    return namespace != "" && path != "" && valid(namespace, "") && valid(path, "/")
+
+}
+
+// Writers
+func(pk *Packet) PutByte(x int8) {
+    pk.put([]byte{byte(x)})
+}
+
+func(pk *Packet) PutUByte(x uint8) {
+    pk.put([]byte{x})
+}
+
+func(pk *Packet) PutShort(x int16) {
+    pk.PutInt(int64(x), 2)
+}
+
+func(pk *Packet) PutUShort(x uint16) {
+    pk.PutInt(int64(x), 2)
+}
+
+// PutInt32 writes an Int
+func(pk *Packet) PutInt32(x int32) {
+    pk.PutInt(int64(x), 4)
+}
+
+func(pk *Packet) PutLong(x int64) {
+    pk.PutInt(x, 8)
+}
+
+func(pk *Packet) PutVarLong(x int64) {
+    pk.put(VarLong(x))
+}
+
+func(pk *Packet) PutIdentifier(s string) {
+    pk.PutString(s)
+}
+
+func(pk *Packet) PutPosition(pos Position) {
+    pk.PutInt(int64(pos.pack()), 8)
+}
+
+// PutAngle writes degrees in steps of 1/256 of a turn
+func(pk *Packet) PutAngle(deg float32) {
+    pk.put([]byte{byte(int(math.Round(float64(deg) * 256 / 360)))})
+}
+
+func(pk *Packet) PutBitSet(bs BitSet) {
+    pk.PutVarInt(int32(len(bs)))
+    for _, x := range bs {
+        pk.PutInt(int64(x), 8)
+    }
+}
+
+// PutBytes writes bytes as they are, for arrays whose length is known
+func(pk *Packet) PutBytes(p []byte) {
+    pk.put(p)
+}
+
+// PutByteArray writes bytes prefixed by their length
+func(pk *Packet) PutByteArray(p []byte) {
+    pk.PutVarInt(int32(len(p)))
+    pk.put(p)
+}
+
+// PutOptional writes whether the value is present, then the value
+func(pk *Packet) PutOptional(present bool, put func()) {
+    pk.PutBool(present)
+    if present {
+        put()
+    }
+}
+
+// PutArray writes the length, then each element
+func(pk *Packet) PutArray(n int, put func(i int)) {
+    pk.PutVarInt(int32(n))
+    for i := 0; i < n; i++ {
+        put(i)
+    }
+}
+
+// PutNBT writes a nameless NBT tag, as sent since 1.20.2
+func(pk *Packet) PutNBT(v interface{}) {
+    pk.put(AppendNBT(nil, v))
+}
+
+// Readers
+func(pr *PacketReader) NextBool() bool {
+    b := pr.NextInt(1)
+    if b > 1 {
+        pr.fail(fmt.Errorf("Invalid boolean %d", b))
+    }
+    return b == 1
+}
+
+func(pr *PacketReader) NextByte() int8 {
+    return int8(pr.NextInt(1))
+}
+
+func(pr *PacketReader) NextUByte() uint8 {
+    return uint8(pr.NextInt(1))
+}
+
+func(pr *PacketReader) NextShort() int16 {
+    return int16(pr.NextInt(2))
+}
+
+func(pr *PacketReader) NextUShort() uint16 {
+    return uint16(pr.NextInt(2))
+}
+
+// NextInt32 reads an Int
+func(pr *PacketReader) NextInt32() int32 {
+    return int32(pr.NextInt(4))
+}
+
+func(pr *PacketReader) NextLong() int64 {
+    return pr.NextInt(8)
+}
+
+func(pr *PacketReader) NextFloat() float32 {
+    return math.Float32frombits(uint32(pr.NextInt(4)))
+}
+
+func(pr *PacketReader) NextDouble() float64 {
+    return math.Float64frombits(uint64(pr.NextInt(8)))
+}
+
+func(pr *PacketReader) NextVarLong() int64 {
+    if pr.err != nil {
+        return 0
+    }
+    x, err := ReadVarLong(pr.r)
+    if err == io.EOF {
+        err = io.ErrUnexpectedEOF
+    }
+    pr.fail(err)
+    return x
+}
+
+func(pr *PacketReader) NextUUID() (uuid [16]byte) {
+    pr.readFull(uuid[:])
+    return
+}
+
+func(pr *PacketReader) NextIdentifier() string {
+    s := pr.NextString()
+    if pr.err == nil && !ValidIdentifier(s) {
+        pr.fail(fmt.Errorf("Invalid identifier %q", s))
+    }
+    return s
+}
+
+func(pr *PacketReader) NextPosition() Position {
+    return unpackPosition(uint64(pr.NextInt(8)))
+}
+
+// NextAngle reads degrees
+func(pr *PacketReader) NextAngle() float32 {
+    return float32(pr.NextInt(1)) * 360 / 256
+}
+
+func(pr *PacketReader) NextBitSet() BitSet {
+    n := pr.nextLength(8)
+    bs := make(BitSet, n)
+    for i := range bs {
+        bs[i] = uint64(pr.NextInt(8))
+    }
+    return bs
+}
+
+// NextBytes reads n bytes
+func(pr *PacketReader) NextBytes(n int) []byte {
+    if n < 0 || n > pr.r.Len() {
+        pr.fail(io.ErrUnexpectedEOF)
+        return nil
+    }
+    p := make([]byte, n)
+    pr.readFull(p)
+    return p
+}
+
+// NextByteArray reads bytes prefixed by their length
+func(pr *PacketReader) NextByteArray() []byte {
+    return pr.NextBytes(pr.nextLength(1))
+}
+
+// NextOptional reads whether a value is present, and the value with next
+// if it is
+func(pr *PacketReader) NextOptional(next func()) bool {
+    present := pr.NextBool()
+    if present && pr.err == nil {
+        next()
+    }
+    return present
+}
+
+// NextArray reads the length of an array, then each element with next.
+// It returns the length.
+func(pr *PacketReader) NextArray(next func(i int)) int {
+    n := pr.nextLength(1)
+    for i := 0; i < n && pr.err == nil; i++ {
+        next(i)
+    }
+    return n
+}
+
+// nextLength reads the length of an array whose elements take at least
+// size bytes, failing if the rest of the packet cannot hold it
+func(pr *PacketReader) nextLength(size int) int {
+    n := pr.NextVarInt()
+    if pr.err != nil {
+        return 0
+    }
+    if n < 0 || int(n) > pr.r.Len() / size {
+        pr.fail(fmt.Errorf("%w: array of %d", io.ErrUnexpectedEOF, n))
+        return 0
+    }
+    return int(n)
+}
+
+// NextNBT reads a nameless NBT tag, see ReadNBT
+func(pr *PacketReader) NextNBT() interface{} {
+    if pr.err != nil {
+        return nil
+    }
+    v, err := ReadNBT(pr.r)
+    pr.fail(err)
+    return v
+}
@@ -0,0 +1,136 @@
+package packet
+
+import (
+    "bytes"
+    "reflect"
+    "testing"
+)
+
+func TestTypes(t *testing.T) {
+
+    uuid := [16]byte{0x86, 0x67, 0xba, 0x71, 0xb8, 0x5a, 0x40, 0x04, 0xaf, 0x54, 0x45, 0x7a, 0x97, 0x34, 0xee, 0xd7}
+
+    samples := []struct {
+        name string
+        put func(*Packet)
+        next func(*PacketReader) interface{}
+        expected interface{}
+        p []byte
+    }{
+        {"bool", func(pk *Packet) { pk.PutBool(true) },
+            func(pr *PacketReader) interface{} { return pr.NextBool() },
+            true, []byte{0x01}},
+        {"byte", func(pk *Packet) { pk.PutByte(-128) },
+            func(pr *PacketReader) interface{} { return pr.NextByte() },
+            int8(-128), []byte{0x80}},
+        {"ubyte", func(pk *Packet) { pk.PutUByte(255) },
+            func(pr *PacketReader) interface{} { return pr.NextUByte() },
+            uint8(255), []byte{0xff}},
+        {"short", func(pk *Packet) { pk.PutShort(-2) },
+            func(pr *PacketReader) interface{} { return pr.NextShort() },
+            int16(-2), []byte{0xff, 0xfe}},
+        {"ushort", func(pk *Packet) { pk.PutUShort(25565) },
+            func(pr *PacketReader) interface{} { return pr.NextUShort() },
+            uint16(25565), []byte{0x63, 0xdd}},
+        {"int", func(pk *Packet) { pk.PutInt32(-2147483648) },
+            func(pr *PacketReader) interface{} { return pr.NextInt32() },
+            int32(-2147483648), []byte{0x80, 0x00, 0x00, 0x00}},
+        {"long", func(pk *Packet) { pk.PutLong(-1) },
+            func(pr *PacketReader) interface{} { return pr.NextLong() },
+            int64(-1), []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+        {"float", func(pk *Packet) { pk.PutFloat(1.5) },
+            func(pr *PacketReader) interface{} { return pr.NextFloat() },
+            float32(1.5), []byte{0x3f, 0xc0, 0x00, 0x00}},
+        {"double", func(pk *Packet) { pk.PutDouble(-2) },
+            func(pr *PacketReader) interface{} { return pr.NextDouble() },
+            float64(-2), []byte{0xc0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}},
+        {"varlong", func(pk *Packet) { pk.PutVarLong(300) },
+            func(pr *PacketReader) interface{} { return pr.NextVarLong() },
+            int64(300), []byte{0xac, 0x02}},
+        {"uuid", func(pk *Packet) { pk.PutUUID(uuid) },
+            func(pr *PacketReader) interface{} { return pr.NextUUID() },
+            uuid, uuid[:]},
+        {"identifier", func(pk *Packet) { pk.PutIdentifier("minecraft:stone") },
+            func(pr *PacketReader) interface{} { return pr.NextIdentifier() },
+            "minecraft:stone", append([]byte{15}, "minecraft:stone"...)},
+        // Example of the wiki, 0100011000000111011000110010110000010101101101001000001100111111
+        {"position", func(pk *Packet) { pk.PutPosition(Position{18357644, 831, -20882616}) },
+            func(pr *PacketReader) interface{} { return pr.NextPosition() },
+            Position{18357644, 831, -20882616}, []byte{0x46, 0x07, 0x63, 0x2c, 0x15, 0xb4, 0x83, 0x3f}},
+        {"angle", func(pk *Packet) { pk.PutAngle(90) },
+            func(pr *PacketReader) interface{} { return pr.NextAngle() },
+            float32(90), []byte{0x40}},
+        {"bitset", func(pk *Packet) { pk.PutBitSet(BitSet{1 << 63 | 1}) },
+            func(pr *PacketReader) interface{} { return pr.NextBitSet() },
+            BitSet{1 << 63 | 1}, []byte{0x01, 0x80, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01}},
+        {"byte array", func(pk *Packet) { pk.PutByteArray([]byte{0xca, 0xfe}) },
+            func(pr *PacketReader) interface{} { return pr.NextByteArray() },
+            []byte{0xca, 0xfe}, []byte{0x02, 0xca, 0xfe}},
+        {"optional", func(pk *Packet) { pk.PutOptional(true, func() { pk.PutVarInt(7) }) },
+            func(pr *PacketReader) interface{} {
+                var x int32
+                pr.NextOptional(func() { x = pr.NextVarInt() })
+                return x
+            },
+            int32(7), []byte{0x01, 0x07}},
+        {"absent", func(pk *Packet) { pk.PutOptional(false, func() { pk.PutVarInt(7) }) },
+            func(pr *PacketReader) interface{} {
+                return pr.NextOptional(func() { pr.NextVarInt() })
+            },
+            false, []byte{0x00}},
+        {"array", func(pk *Packet) {
+                strs := []string{"a", "bc"}
+                pk.PutArray(len(strs), func(i int) { pk.PutString(strs[i]) })
+            },
+            func(pr *PacketReader) interface{} {
+                strs := make([]string, 0)
+                pr.NextArray(func(int) { strs = append(strs, pr.NextString()) })
+                return strs
+            },
+            []string{"a", "bc"}, []byte{0x02, 0x01, 'a', 0x02, 'b', 'c'}},
+        {"nbt", func(pk *Packet) { pk.PutNBT(map[string] interface{}{"a": int8(1)}) },
+            func(pr *PacketReader) interface{} { return pr.NextNBT() },
+            map[string] interface{}{"a": int8(1)}, []byte{0x0a, 0x01, 0x00, 0x01, 'a', 0x01, 0x00}},
+    }
+
+    for _, sample := range samples {
+        pk := NewPacket(0)
+        sample.put(pk)
+        if !bytes.Equal(pk.data, sample.p) {
+            t.Errorf("%s) %x != %x", sample.name, pk.data, sample.p)
+        }
+
+        pr := &PacketReader{r: bytes.NewReader(sample.p)}
+        v := sample.next(pr)
+        if pr.Err() != nil || pr.Len() != 0 || !reflect.DeepEqual(v, sample.expected) {
+            t.Errorf("%s) %v != %v, %v", sample.name, v, sample.expected, pr.Err())
+        }
+    }
+
+}
+
+func TestTypeErrors(t *testing.T) {
+
+    samples := map[string] func(*PacketReader){
+        "bool": func(pr *PacketReader) { pr.NextBool() },
+        "identifier": func(pr *PacketReader) { pr.NextIdentifier() },
+        "array": func(pr *PacketReader) { pr.NextArray(func(int) { pr.NextVarInt() }) },
+        "bitset": func(pr *PacketReader) { pr.NextBitSet() },
+    }
+    inputs := map[string] []byte{
+        "bool": {0x02},
+        "identifier": {0x03, 'A', ':', 'b'},
+        "array": {0xff, 0xff, 0xff, 0xff, 0x07},
+        "bitset": {0x02, 0x00},
+    }
+
+    for name, next := range samples {
+        pr := &PacketReader{r: bytes.NewReader(inputs[name])}
+        next(pr)
+        t.Logf("%s) %v", name, pr.Err())
+        if pr.Err() == nil {
+            t.Errorf("%s) no error", name)
+        }
+    }
+
+}
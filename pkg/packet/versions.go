@@ -0,0 +1,183 @@
+package packet
+
+import (
+    "sort"
+    "strings"
+)
+
+// Versions
+// Protocol numbers of releases and the IDs packets have in them
+// https://minecraft.wiki/w/Protocol_version_numbers
+
+// Version is a protocol number and the releases that speak it
+type Version struct {
+    Protocol int32
+    Releases []string
+}
+
+// Name joins the releases, e.g. 1.16.4/1.16.5
+func(v Version) Name() string {
+    return strings.Join(v.Releases, "/")
+}
+
+// Versions are sorted by protocol
+var Versions = []Version{
+    {4, []string{"1.7.2", "1.7.4", "1.7.5"}},
+    {5, []string{"1.7.6", "1.7.7", "1.7.8", "1.7.9", "1.7.10"}},
+    {47, []string{"1.8", "1.8.1", "1.8.2", "1.8.3", "1.8.4", "1.8.5", "1.8.6", "1.8.7", "1.8.8", "1.8.9"}},
+    {107, []string{"1.9"}},
+    {108, []string{"1.9.1"}},
+    {109, []string{"1.9.2"}},
+    {110, []string{"1.9.3", "1.9.4"}},
+    {210, []string{"1.10", "1.10.1", "1.10.2"}},
+    {315, []string{"1.11"}},
+    {316, []string{"1.11.1", "1.11.2"}},
+    {335, []string{"1.12"}},
+    {338, []string{"1.12.1"}},
+    {340, []string{"1.12.2"}},
+    {393, []string{"1.13"}},
+    {401, []string{"1.13.1"}},
+    {404, []string{"1.13.2"}},
+    {477, []string{"1.14"}},
+    {480, []string{"1.14.1"}},
+    {485, []string{"1.14.2"}},
+    {490, []string{"1.14.3"}},
+    {498, []string{"1.14.4"}},
+    {573, []string{"1.15"}},
+    {575, []string{"1.15.1"}},
+    {578, []string{"1.15.2"}},
+    {735, []string{"1.16"}},
+    {736, []string{"1.16.1"}},
+    {751, []string{"1.16.2"}},
+    {753, []string{"1.16.3"}},
+    {754, []string{"1.16.4", "1.16.5"}},
+    {755, []string{"1.17"}},
+    {756, []string{"1.17.1"}},
+    {757, []string{"1.18", "1.18.1"}},
+    {758, []string{"1.18.2"}},
+    {759, []string{"1.19"}},
+    {760, []string{"1.19.1", "1.19.2"}},
+    {761, []string{"1.19.3"}},
+    {762, []string{"1.19.4"}},
+    {763, []string{"1.20", "1.20.1"}},
+    {764, []string{"1.20.2"}},
+    {765, []string{"1.20.3", "1.20.4"}},
+    {766, []string{"1.20.5", "1.20.6"}},
+    {767, []string{"1.21", "1.21.1"}},
+    {768, []string{"1.21.2", "1.21.3"}},
+    {769, []string{"1.21.4"}},
+    {770, []string{"1.21.5"}},
+    {771, []string{"1.21.6"}},
+    {772, []string{"1.21.7", "1.21.8"}},
+}
+
+// LookupVersion finds the version of a protocol number
+func LookupVersion(protocol int32) (Version, bool) {
+    i := sort.Search(len(Versions), func(i int) bool {
+        return Versions[i].Protocol >= protocol
+    })
+    if i < len(Versions) && Versions[i].Protocol == protocol {
+        return Versions[i], true
+    }
+    return Version{}, false
+}
+
+// VersionName is the name of the protocol's releases, or "" if unknown
+func VersionName(protocol int32) string {
+    v, _ := LookupVersion(protocol)
+    return v.Name()
+}
+
+// EchoVersion is the version to put in synthesized status responses,
+// which is the client's own so that it is not listed as incompatible
+func EchoVersion(protocol int32) VersionStruct {
+    return VersionStruct{
+        Name: VersionName(protocol),
+        Protocol: int(protocol),
+    }
+}
+
+// PacketType is a packet regardless of its ID, which differs by version
+type PacketType int
+
+const (
+    // Handshaking
+    PacketHandshake PacketType = iota
+    // Status
+    PacketStatusRequest
+    PacketStatusResponse
+    PacketStatusPing
+    PacketStatusPong
+    // Login
+    PacketLoginStart
+    PacketLoginDisconnect
+    PacketLoginEncryptionRequest
+    PacketLoginSuccess
+    PacketLoginSetCompression
+    PacketLoginPluginRequest
+    PacketLoginPluginResponse
+    PacketLoginAcknowledged
+    // Configuration
+    PacketConfigDisconnect
+    PacketConfigFinish
+    PacketConfigRegistry
+    PacketConfigKnownPacks
+    PacketConfigFinishAck
+    PacketConfigKnownPacksAck
+    // Play
+    PacketPlayLogin
+    PacketPlayKeepAlive
+    PacketPlayPosition
+    PacketPlayGameEvent
+    PacketPlayTransfer
+    PacketPlayDisconnect
+)
+
+// idRange is the ID of a packet from one protocol to another, inclusive;
+// from being 0 means it has always been, and to being 0 that it has not
+// changed since
+type idRange struct {
+    from, to int32
+    id int
+}
+
+// packetIDs lists only what is known, so a protocol outside of the ranges
+// is not guessed at
+var packetIDs = map[PacketType] []idRange{
+    PacketHandshake: {{0, 0, IDHandshake}},
+    PacketStatusRequest: {{0, 0, IDStatusSLP}},
+    PacketStatusResponse: {{0, 0, IDStatusSLP}},
+    PacketStatusPing: {{0, 0, IDStatusPingPong}},
+    PacketStatusPong: {{0, 0, IDStatusPingPong}},
+    PacketLoginStart: {{0, 0, IDLoginStart}},
+    PacketLoginDisconnect: {{0, 0, IDLoginDisconnect}},
+    PacketLoginEncryptionRequest: {{0, 0, 0x01}},
+    PacketLoginSuccess: {{0, 0, IDLoginSuccess}},
+    PacketLoginSetCompression: {{47, 0, 0x03}},
+    PacketLoginPluginRequest: {{393, 0, IDLoginPluginRequest}},
+    PacketLoginPluginResponse: {{393, 0, IDLoginPluginResponse}},
+    PacketLoginAcknowledged: {{764, 0, IDLoginAcknowledged}},
+    PacketConfigDisconnect: {{764, 765, 0x01}, {766, 767, 0x02}},
+    PacketConfigFinish: {{764, 765, 0x02}, {766, 767, 0x03}},
+    PacketConfigRegistry: {{764, 765, 0x05}, {766, 767, 0x07}},
+    PacketConfigKnownPacks: {{766, 767, 0x0e}},
+    PacketConfigFinishAck: {{764, 765, 0x02}, {766, 767, 0x03}},
+    PacketConfigKnownPacksAck: {{766, 767, 0x07}},
+    PacketPlayLogin: {{766, 767, 0x2b}},
+    PacketPlayKeepAlive: {{766, 767, 0x26}},
+    PacketPlayPosition: {{766, 767, 0x40}},
+    PacketPlayGameEvent: {{766, 767, 0x22}},
+    PacketPlayTransfer: {{766, 767, 0x73}},
+    PacketPlayDisconnect: {{766, 767, 0x1d}},
+}
+
+// PacketID returns the ID of the packet in the protocol, and false if the
+// packet does not exist in it or its ID is not known
+func PacketID(typ PacketType, protocol int32) (int, bool) {
+    for _, r := range packetIDs[typ] {
+        if (r.from == 0 || protocol >= r.from) && (r.to == 0 || protocol <= r.to) {
+            return r.id, true
+        }
+    }
+    return 0, false
+}
@@ -0,0 +1,88 @@
+package packet
+
+import (
+    "testing"
+)
+
+func TestVersions(t *testing.T) {
+
+    for i := 1; i < len(Versions); i++ {
+        if Versions[i - 1].Protocol >= Versions[i].Protocol {
+            t.Error("Versions are not sorted at", Versions[i].Protocol)
+        }
+    }
+
+    samples := []struct {
+        protocol int32
+        name string
+    }{
+        {4, "1.7.2/1.7.4/1.7.5"},
+        {47, "1.8/1.8.1/1.8.2/1.8.3/1.8.4/1.8.5/1.8.6/1.8.7/1.8.8/1.8.9"},
+        {754, "1.16.4/1.16.5"},
+        {767, "1.21/1.21.1"},
+        {-1, ""},
+        {9999, ""},
+    }
+
+    for _, sample := range samples {
+        if name := VersionName(sample.protocol); name != sample.name {
+            t.Errorf("%d: expected %q, got %q", sample.protocol, sample.name, name)
+        }
+    }
+
+    v := EchoVersion(754)
+    if v.Protocol != 754 || v.Name != "1.16.4/1.16.5" {
+        t.Error("Wrong echoed version", v)
+    }
+
+}
+
+func TestPacketID(t *testing.T) {
+
+    samples := []struct {
+        typ PacketType
+        protocol int32
+        id int
+        ok bool
+    }{
+        {PacketHandshake, -1, 0x00, true},
+        {PacketStatusPing, 4, 0x01, true},
+        {PacketLoginSetCompression, 5, 0, false},
+        {PacketLoginSetCompression, 47, 0x03, true},
+        {PacketLoginPluginRequest, 340, 0, false},
+        {PacketLoginPluginRequest, 393, 0x04, true},
+        {PacketLoginPluginRequest, 767, 0x04, true},
+        {PacketLoginAcknowledged, 763, 0, false},
+        {PacketLoginAcknowledged, 764, 0x03, true},
+        {PacketConfigFinish, 764, 0x02, true},
+        {PacketConfigFinish, 766, 0x03, true},
+        {PacketConfigKnownPacks, 765, 0, false},
+        {PacketPlayTransfer, 767, 0x73, true},
+        {PacketPlayTransfer, 768, 0, false},
+    }
+
+    for _, sample := range samples {
+        id, ok := PacketID(sample.typ, sample.protocol)
+        if id != sample.id || ok != sample.ok {
+            t.Errorf("Packet %d in %d: expected %#x %v, got %#x %v",
+                sample.typ, sample.protocol, sample.id, sample.ok, id, ok)
+        }
+    }
+
+}
+
+// Every packet the limbo sends has to be known in the versions it holds
+func TestLimboVersions(t *testing.T) {
+
+    for protocol := range limboVersions {
+        if _, ok := LookupVersion(protocol); !ok {
+            t.Error("Limbo version is not in Versions:", protocol)
+        }
+        for typ := PacketLoginSuccess; typ <= PacketPlayDisconnect; typ++ {
+            if _, ok := PacketID(typ, protocol); !ok {
+                t.Errorf("Packet %d has no ID in limbo version %d", typ, protocol)
+            }
+        }
+    }
+
+}
@@ -0,0 +1,183 @@
+package rcon
+
+import (
+    "bytes"
+    "encoding/binary"
+    "fmt"
+    "io"
+    "net"
+    "sync"
+    "time"
+)
+
+// Source RCON protocol
+// https://developer.valvesoftware.com/wiki/Source_RCON_Protocol
+const (
+    TypeResponse = 0
+    TypeCommand = 2
+    TypeAuthResponse = 2
+    TypeAuth = 3
+    MaxPayload = 4096 // bodies longer than this are split by the server
+    maxPacket = 4 + 4 + 4 + MaxPayload + 2
+)
+
+var (
+    ErrAuth = fmt.Errorf("RCON authentication failed")
+    ErrTooLong = fmt.Errorf("RCON packet too long")
+)
+
+type Packet struct {
+    ID int32
+    Type int32
+    Body string
+}
+
+func(pk Packet) Bytes() []byte {
+
+    var buf bytes.Buffer
+    le := binary.LittleEndian
+
+    binary.Write(&buf, le, int32(4 + 4 + len(pk.Body) + 2))
+    binary.Write(&buf, le, pk.ID)
+    binary.Write(&buf, le, pk.Type)
+    buf.WriteString(pk.Body)
+    buf.Write([]byte{0, 0})
+
+    return buf.Bytes()
+
+}
+
+func ReadPacket(r io.Reader) (pk Packet, err error) {
+
+    le := binary.LittleEndian
+
+    var l int32
+    err = binary.Read(r, le, &l)
+    if err != nil {
+        return pk, err
+    }
+    if l < 10 || l > maxPacket {
+        return pk, ErrTooLong
+    }
+
+    p := make([]byte, l)
+    _, err = io.ReadFull(r, p)
+    if err != nil {
+        return pk, err
+    }
+
+    pk.ID = int32(le.Uint32(p[0:]))
+    pk.Type = int32(le.Uint32(p[4:]))
+    pk.Body = string(bytes.TrimRight(p[8:], "\x00"))
+
+    return pk, nil
+
+}
+
+// Client
+type Client struct {
+    conn net.Conn
+    id int32
+    lock sync.Mutex
+}
+
+// Dial connects and authenticates, timeout applies to each exchange
+func Dial(addr, password string, timeout time.Duration) (*Client, error) {
+
+    conn, err := net.DialTimeout("tcp", addr, timeout)
+    if err != nil {
+        return nil, err
+    }
+
+    c := &Client{conn: conn}
+    conn.SetDeadline(time.Now().Add(timeout))
+
+    err = c.auth(password)
+    if err != nil {
+        conn.Close()
+        return nil, err
+    }
+
+    conn.SetDeadline(time.Time{})
+    return c, nil
+
+}
+
+func(c *Client) nextID() int32 {
+    c.id++
+    return c.id
+}
+
+func(c *Client) auth(password string) error {
+
+    id := c.nextID()
+    _, err := c.conn.Write(Packet{id, TypeAuth, password}.Bytes())
+    if err != nil {
+        return err
+    }
+
+    for {
+        pk, err := ReadPacket(c.conn)
+        if err != nil {
+            return err
+        }
+        // Source servers send an empty response first
+        if pk.Type != TypeAuthResponse {
+            continue
+        }
+        if pk.ID == -1 || pk.ID != id {
+            return ErrAuth
+        }
+        return nil
+    }
+
+}
+
+// Command runs the command and returns the whole response, which may have
+// come in several packets
+func(c *Client) Command(cmd string) (string, error) {
+
+    c.lock.Lock()
+    defer c.lock.Unlock()
+
+    if len(cmd) > MaxPayload {
+        return "", ErrTooLong
+    }
+
+    // The server answers in order, so the response to the empty packet
+    // marks the end of the command response
+    id, end := c.nextID(), c.nextID()
+    _, err := c.conn.Write(Packet{id, TypeCommand, cmd}.Bytes())
+    if err != nil {
+        return "", err
+    }
+    _, err = c.conn.Write(Packet{end, TypeResponse, ""}.Bytes())
+    if err != nil {
+        return "", err
+    }
+
+    var body string
+    for {
+        pk, err := ReadPacket(c.conn)
+        if err != nil {
+            return body, err
+        }
+        switch pk.ID {
+        case id:
+            body += pk.Body
+        case end:
+            return body, nil
+        case -1:
+            return body, ErrAuth
+        }
+    }
+
+}
+
+func(c *Client) SetDeadline(t time.Time) error {
+    return c.conn.SetDeadline(t)
+}
+
+func(c *Client) Close() error {
+    return c.conn.Close()
+}
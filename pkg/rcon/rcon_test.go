@@ -0,0 +1,123 @@
+package rcon
+
+import (
+    "fmt"
+    "net"
+    "strings"
+    "testing"
+    "time"
+)
+
+// fakeServer behaves like the rcon of a minecraft server
+func fakeServer(t *testing.T, password string, handle func(string) string) string {
+
+    ln, err := net.Listen("tcp", "localhost:0")
+    if err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() { ln.Close() })
+
+    go func() {
+        for {
+            conn, err := ln.Accept()
+            if err != nil {
+                return
+            }
+            go func() {
+                defer conn.Close()
+                authed := false
+                for {
+                    pk, err := ReadPacket(conn)
+                    if err != nil {
+                        return
+                    }
+                    switch {
+                    case pk.Type == TypeAuth:
+                        authed = pk.Body == password
+                        id := pk.ID
+                        if !authed {
+                            id = -1
+                        }
+                        conn.Write(Packet{id, TypeAuthResponse, ""}.Bytes())
+                    case !authed:
+                        conn.Write(Packet{-1, TypeAuthResponse, ""}.Bytes())
+                    case pk.Type == TypeCommand:
+                        body := handle(pk.Body)
+                        for len(body) > MaxPayload {
+                            conn.Write(Packet{pk.ID, TypeResponse, body[:MaxPayload]}.Bytes())
+                            body = body[MaxPayload:]
+                        }
+                        conn.Write(Packet{pk.ID, TypeResponse, body}.Bytes())
+                    default:
+                        body := fmt.Sprintf("Unknown request %x", pk.Type)
+                        conn.Write(Packet{pk.ID, TypeResponse, body}.Bytes())
+                    }
+                }
+            }()
+        }
+    }()
+
+    return ln.Addr().String()
+
+}
+
+func TestRCONCommand(t *testing.T) {
+
+    long := strings.Repeat("0123456789", 1000)
+    addr := fakeServer(t, "pass", func(cmd string) string {
+        switch cmd {
+        case "list":
+            return "There are 0 of a max of 20 players online: "
+        case "long":
+            return long
+        }
+        return "Unknown command"
+    })
+
+    c, err := Dial(addr, "pass", time.Second)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer c.Close()
+
+    samples := map[string] string{
+        "list": "There are 0 of a max of 20 players online: ",
+        "long": long,
+        "nope": "Unknown command",
+    }
+    for cmd, expected := range samples {
+        body, err := c.Command(cmd)
+        result := err == nil && body == expected
+        t.Logf("%s) %d bytes, %t", cmd, len(body), result)
+        if !result {
+            t.Fail()
+        }
+    }
+
+}
+
+func TestRCONAuth(t *testing.T) {
+
+    addr := fakeServer(t, "pass", func(string) string { return "" })
+
+    _, err := Dial(addr, "wrong", time.Second)
+    if err != ErrAuth {
+        t.Errorf("Expected ErrAuth, got %v", err)
+    }
+
+}
+
+func TestPacketBytes(t *testing.T) {
+
+    pk := Packet{7, TypeCommand, "stop"}
+    expected := []byte{
+        0x0e, 0x00, 0x00, 0x00,
+        0x07, 0x00, 0x00, 0x00,
+        0x02, 0x00, 0x00, 0x00,
+        's', 't', 'o', 'p', 0x00, 0x00,
+    }
+    if fmt.Sprintf("%x", pk.Bytes()) != fmt.Sprintf("%x", expected) {
+        t.Errorf("%x != %x", pk.Bytes(), expected)
+    }
+
+}
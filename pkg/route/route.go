@@ -0,0 +1,157 @@
+package route
+
+import (
+    "fmt"
+    "net"
+    "os"
+    "regexp"
+    "strconv"
+    "strings"
+)
+
+// Table
+// Finds the server of a hostname, the servers being keys given by the
+// caller. Patterns are, in order of precedence:
+//   mc.example.com          exact
+//   *.play.example.com      wildcard of one or more labels, the longest
+//                           suffix first
+//   ~(\w+)\.example\.com    regex, anchored at both ends, in the order
+//                           they were added
+// Matching is case-insensitive and ignores a trailing dot. Hostnames that
+// match nothing, and IP literals that are not exact names, get the default.
+type Table struct {
+    exact map[string] string
+    wildcards map[string] string // by suffix, without "*."
+    regexps []regexRoute
+    fallback string
+}
+
+type regexRoute struct {
+    re *regexp.Regexp
+    key string
+}
+
+// Match is the result of a lookup
+type Match struct {
+    Key string
+    Hostname string // normalized
+    captures []string // the hostname first
+    names []string // of the captures, "" for unnamed ones
+}
+
+const regexPrefix = "~"
+
+func NewTable() *Table {
+    return &Table{
+        exact: make(map[string] string),
+        wildcards: make(map[string] string),
+    }
+}
+
+// Normalize lower-cases the hostname and removes a trailing dot
+func Normalize(hostname string) string {
+    return strings.ToLower(strings.TrimSuffix(hostname, "."))
+}
+
+// Add routes the hostnames of the pattern to the key
+func(t *Table) Add(pattern, key string) error {
+
+    if strings.HasPrefix(pattern, regexPrefix) {
+        expr := strings.TrimPrefix(pattern, regexPrefix)
+        re, err := regexp.Compile("(?i)^(?:" + expr + ")$")
+        if err != nil {
+            return fmt.Errorf("Route %s: %v", pattern, err)
+        }
+        t.regexps = append(t.regexps, regexRoute{re, key})
+        return nil
+    }
+
+    name := Normalize(pattern)
+    table, taken := t.exact, name
+    if strings.HasPrefix(name, "*.") {
+        table, taken = t.wildcards, strings.TrimPrefix(name, "*.")
+    }
+    if taken == "" || strings.Contains(taken, "*") {
+        return fmt.Errorf("Invalid route %s", pattern)
+    }
+    if old, ok := table[taken]; ok {
+        return fmt.Errorf("Route %s is taken by %s", pattern, old)
+    }
+    table[taken] = key
+
+    return nil
+
+}
+
+// SetDefault sets the key of hostnames that match nothing, "" for none
+func(t *Table) SetDefault(key string) {
+    t.fallback = key
+}
+
+func(t *Table) Lookup(hostname string) (Match, bool) {
+
+    host := Normalize(hostname)
+    m := Match{Hostname: host, captures: []string{host}, names: []string{""}}
+
+    if key, ok := t.exact[host]; ok {
+        m.Key = key
+        return m, true
+    }
+
+    // Addresses are not names
+    literal := strings.Trim(host, "[]")
+    if net.ParseIP(literal) == nil {
+        // Suffixes from the longest
+        for i := strings.IndexByte(host, '.'); i >= 0; {
+            if key, ok := t.wildcards[host[i + 1:]]; ok {
+                m.Key = key
+                m.captures = append(m.captures, host[:i])
+                m.names = append(m.names, "")
+                return m, true
+            }
+            next := strings.IndexByte(host[i + 1:], '.')
+            if next < 0 {
+                break
+            }
+            i += 1 + next
+        }
+
+        for _, route := range t.regexps {
+            captures := route.re.FindStringSubmatch(host)
+            if captures != nil {
+                m.Key = route.key
+                m.captures = captures
+                m.names = route.re.SubexpNames()
+                return m, true
+            }
+        }
+    }
+
+    if t.fallback != "" {
+        m.Key = t.fallback
+        return m, true
+    }
+
+    return Match{}, false
+
+}
+
+// Expand replaces $1 or ${1} with the captures of the match, and ${name}
+// with named ones. $0 is the hostname, and for wildcards $1 is what the
+// asterisk matched.
+func(m Match) Expand(template string) string {
+    return os.Expand(template, func(name string) string {
+        if i, err := strconv.Atoi(name); err == nil {
+            if i >= 0 && i < len(m.captures) {
+                return m.captures[i]
+            }
+            return ""
+        }
+        for i, each := range m.names {
+            if each != "" && each == name {
+                return m.captures[i]
+            }
+        }
+        return ""
+    })
+}
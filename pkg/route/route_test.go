@@ -0,0 +1,136 @@
+package route
+
+import (
+    "testing"
+)
+
+func TestLookup(t *testing.T) {
+
+    table := NewTable()
+    for _, route := range []struct {
+        pattern, key string
+    }{
+        {"mc.example.com", "exact"},
+        {"Lobby.Example.com.", "lobby"},
+        {"10.0.0.1", "ip"},
+        {"*.example.com", "wildcard"},
+        {"*.play.example.com", "play"},
+        {"mc.play.example.com", "exact-play"},
+        {`~(\w+)\.mc\.example\.org`, "regex"},
+        {`~(?P<world>\w+)\.(?P<region>eu|us)\.example\.org`, "named"},
+        {`~.*\.example\.org`, "catch"},
+        {`~mc\.example\.com`, "shadowed"},
+    } {
+        if err := table.Add(route.pattern, route.key); err != nil {
+            t.Fatal(err)
+        }
+    }
+
+    samples := []struct {
+        hostname string
+        key string
+        ok bool
+    }{
+        // Exact names come first, however they are written
+        {"mc.example.com", "exact", true},
+        {"MC.Example.COM", "exact", true},
+        {"mc.example.com.", "exact", true},
+        {"lobby.example.com", "lobby", true},
+        {"mc.play.example.com", "exact-play", true},
+        // Then the longest wildcard suffix
+        {"a.example.com", "wildcard", true},
+        {"a.b.example.com", "wildcard", true},
+        {"a.play.example.com", "play", true},
+        {"a.b.play.example.com", "play", true},
+        {"example.com", "", false},
+        // Then regexes in order, anchored
+        {"survival.mc.example.org", "regex", true},
+        {"survival.eu.example.org", "named", true},
+        {"a.b.example.org", "catch", true},
+        {"example.org", "", false},
+        {"survival.mc.example.org.evil.com", "", false},
+        // Addresses only match exactly
+        {"10.0.0.1", "ip", true},
+        {"10.0.0.2", "", false},
+        {"", "", false},
+    }
+
+    check := func() {
+        for _, sample := range samples {
+            m, ok := table.Lookup(sample.hostname)
+            if m.Key != sample.key || ok != sample.ok {
+                t.Errorf("%q: expected %q %v, got %q %v", sample.hostname, sample.key, sample.ok, m.Key, ok)
+            }
+        }
+    }
+    check()
+
+    // The default takes the rest
+    table.SetDefault("default")
+    for i := range samples {
+        if !samples[i].ok {
+            samples[i].key, samples[i].ok = "default", true
+        }
+    }
+    samples = append(samples, []struct {
+        hostname string
+        key string
+        ok bool
+    }{
+        {"[::1]", "default", true},
+        {"192.168.0.10", "default", true},
+        {"10.0.0.1.example.com", "wildcard", true},
+    }...)
+    check()
+
+}
+
+func TestAdd(t *testing.T) {
+
+    table := NewTable()
+    if err := table.Add("mc.example.com", "a"); err != nil {
+        t.Fatal(err)
+    }
+
+    for _, pattern := range []string{
+        "MC.example.com.",
+        "",
+        "*.",
+        "a.*.example.com",
+        "~(",
+    } {
+        if table.Add(pattern, "b") == nil {
+            t.Errorf("%q was added", pattern)
+        }
+    }
+
+}
+
+func TestExpand(t *testing.T) {
+
+    table := NewTable()
+    table.Add("*.play.example.com", "wildcard")
+    table.Add(`~(?P<world>\w+)\.(eu|us)\.example\.org`, "regex")
+    table.SetDefault("default")
+
+    samples := []struct {
+        hostname, template, expected string
+    }{
+        {"Survival.play.example.com", "$1.internal:25565", "survival.internal:25565"},
+        {"a.b.play.example.com", "${1}:25565", "a.b:25565"},
+        {"survival.eu.example.org", "${world}-$2.internal:25565", "survival-eu.internal:25565"},
+        {"survival.eu.example.org", "$0 $3 ${none}", "survival.eu.example.org  "},
+        {"10.0.0.1", "$0 $1", "10.0.0.1 "},
+    }
+
+    for _, sample := range samples {
+        m, ok := table.Lookup(sample.hostname)
+        if !ok {
+            t.Fatal(sample.hostname)
+        }
+        if s := m.Expand(sample.template); s != sample.expected {
+            t.Errorf("%q %q: expected %q, got %q", sample.hostname, sample.template, sample.expected, s)
+        }
+    }
+
+}